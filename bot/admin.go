@@ -0,0 +1,383 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+// adminUsage подсказка по подкомандам /admin
+const adminUsage = `🛡 Команды администратора:
+
+/admin stats - Общая статистика бота
+/admin whois <ID> - Автор голосования и где оно опубликовано
+/admin closepoll <ID> - Завершить любое голосование
+/admin deletepoll <ID> - Удалить любое голосование вместе с голосами
+/admin ban <ID|@username> - Запретить пользователю создавать голосования и голосовать
+/admin unban <ID|@username> - Снять блокировку`
+
+// bannedMessage ответ заблокированному пользователю
+const bannedMessage = "🚫 Вы заблокированы администратором бота и не можете создавать голосования и голосовать."
+
+// deletedPollMessage текст, которым заменяются опубликованные сообщения удаленного голосования
+const deletedPollMessage = "🗑 Голосование удалено администратором."
+
+// isAdmin сообщает, входит ли пользователь в список администраторов (ADMIN_IDS)
+func (b *Bot) isAdmin(userID int64) bool {
+	_, ok := b.admins[userID]
+	return ok
+}
+
+// loadBannedUsers заново читает заблокированных пользователей из хранилища и заменяет
+// копию в памяти целиком, чтобы подхватить блокировки, снятые на других экземплярах бота
+func (b *Bot) loadBannedUsers(ctx context.Context) error {
+	users, err := b.store.ListBannedUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось загрузить заблокированных пользователей: %w", err)
+	}
+	banned := make(map[int64]struct{}, len(users))
+	for _, userID := range users {
+		banned[userID] = struct{}{}
+	}
+	b.bannedMu.Lock()
+	defer b.bannedMu.Unlock()
+	b.banned = banned
+	return nil
+}
+
+// startBanRefresh запускает горутину, которая периодически перечитывает список
+// заблокированных из базы. При ошибке остается прежняя копия.
+func (b *Bot) startBanRefresh() {
+	go func() {
+		ticker := time.NewTicker(b.banRefresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				if err := b.loadBannedUsers(context.Background()); err != nil {
+					b.log.Error("failed to refresh banned users", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// isBanned сообщает, заблокирован ли пользователь. Проверяется копия в памяти,
+// поэтому проверка не обращается к базе данных на каждое нажатие.
+func (b *Bot) isBanned(userID int64) bool {
+	b.bannedMu.RLock()
+	defer b.bannedMu.RUnlock()
+	_, ok := b.banned[userID]
+	return ok
+}
+
+// setBanned обновляет копию в памяти после записи блокировки в хранилище
+func (b *Bot) setBanned(userID int64, banned bool) {
+	b.bannedMu.Lock()
+	defer b.bannedMu.Unlock()
+	if banned {
+		b.banned[userID] = struct{}{}
+	} else {
+		delete(b.banned, userID)
+	}
+}
+
+// handleAdmin обрабатывает команду /admin <подкоманда> (только администраторы, только личный чат)
+func (b *Bot) handleAdmin(c telebot.Context) error {
+	userID := c.Sender().ID
+	if !b.isAdmin(userID) {
+		b.log.Warn("admin command denied", "user_id", userID)
+		return c.Send("❌ Команда доступна только администраторам бота.")
+	}
+	if c.Chat().Type != telebot.ChatPrivate {
+		return c.Send("❌ Команды администратора доступны только в личном чате с ботом.")
+	}
+
+	args := strings.Fields(c.Text())
+	if len(args) < 2 {
+		return c.Send(adminUsage)
+	}
+
+	switch args[1] {
+	case "stats":
+		return b.handleAdminStats(c)
+	case "whois":
+		return b.handleAdminWhois(c, args[2:])
+	case "closepoll":
+		return b.handleAdminClosePoll(c, args[2:])
+	case "deletepoll":
+		return b.handleAdminDeletePoll(c, args[2:])
+	case "ban":
+		return b.handleAdminBan(c, args[2:], true)
+	case "unban":
+		return b.handleAdminBan(c, args[2:], false)
+	default:
+		return c.Send(fmt.Sprintf("❌ Неизвестная подкоманда %q\n\n%s", args[1], adminUsage))
+	}
+}
+
+// adminPollID извлекает ID голосования из аргументов подкоманды и сообщает об ошибке пользователю
+func adminPollID(c telebot.Context, subcommand string, args []string) (int64, bool) {
+	if len(args) == 0 {
+		c.Send(fmt.Sprintf("❌ Укажите ID голосования.\n\nИспользование: /admin %s <ID>", subcommand))
+		return 0, false
+	}
+	pollID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		c.Send("❌ Некорректный ID голосования")
+		return 0, false
+	}
+	return pollID, true
+}
+
+// handleAdminStats показывает общие счетчики по всем голосованиям
+func (b *Bot) handleAdminStats(c telebot.Context) error {
+	stats, err := b.store.GetStats(context.Background())
+	if err != nil {
+		b.log.Error("failed to get stats", "error", err)
+		return c.Send("❌ Ошибка получения статистики")
+	}
+
+	queue := b.updateQueue.Stats()
+	return c.Send(fmt.Sprintf("📊 Статистика бота\n\n"+
+		"🗳 Голосований: %d (открыто: %d)\n"+
+		"👤 Авторов: %d\n"+
+		"✅ Голосов: %d, бюллетеней: %d\n"+
+		"👥 Участников: %d\n"+
+		"📢 Публикаций: %d\n"+
+		"📜 Нажатий в vote_log: %d\n"+
+		"🚫 Заблокировано пользователей: %d\n\n"+
+		"📨 Очередь обновлений: ждут %d, отложено %d",
+		stats.Polls, stats.ActivePolls, stats.Creators, stats.Votes, stats.Ballots, stats.Voters,
+		stats.Publications, stats.VoteLog, stats.BannedUsers, queue.Pending, queue.Delayed))
+}
+
+// handleAdminWhois показывает автора голосования и все его публикации
+func (b *Bot) handleAdminWhois(c telebot.Context, args []string) error {
+	pollID, ok := adminPollID(c, "whois", args)
+	if !ok {
+		return nil
+	}
+
+	ctx := context.Background()
+	poll, err := b.store.GetPoll(ctx, pollID)
+	if errors.Is(err, errPollNotFound) {
+		return c.Send("❌ Голосование не найдено")
+	}
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка получения голосования")
+	}
+	chats, err := b.store.ListPollChats(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to list poll chats", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка получения публикаций")
+	}
+
+	owner := fmt.Sprintf("ID %d", poll.CreatorID)
+	if poll.CreatorUsername != "" {
+		owner = fmt.Sprintf("@%s (ID %d)", poll.CreatorUsername, poll.CreatorID)
+	}
+	if b.isBanned(poll.CreatorID) {
+		owner += " — 🚫 заблокирован"
+	}
+	status := "открыто"
+	if poll.IsClosed() {
+		status = "завершено"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔎 Голосование %d: «%s»\n\n", poll.ID, poll.Title)
+	fmt.Fprintf(&sb, "👤 Автор: %s\n", owner)
+	fmt.Fprintf(&sb, "📌 Статус: %s, участников: %d\n\n", status, poll.TotalVoters)
+
+	if len(chats) == 0 {
+		sb.WriteString("📢 Голосование еще не опубликовано.")
+		return c.Send(sb.String())
+	}
+	fmt.Fprintf(&sb, "📢 Публикации (%d):\n", len(chats))
+	labels := make(map[int64]string)
+	for _, chat := range chats {
+		if chat.InlineMessageID != "" {
+			sb.WriteString("• inline-сообщение (чат неизвестен)\n")
+			continue
+		}
+		label, exists := labels[chat.ChatID]
+		if !exists {
+			label = b.chatLabel(chat.ChatID)
+			labels[chat.ChatID] = label
+		}
+		fmt.Fprintf(&sb, "• %s, сообщение %d\n", label, chat.MessageID)
+	}
+	return c.Send(sb.String())
+}
+
+// chatLabel описывает чат публикации по данным getChat (если бот не может их получить — только ID)
+func (b *Bot) chatLabel(chatID int64) string {
+	chat, err := b.bot.ChatByID(chatID)
+	if err != nil {
+		b.log.Debug("failed to get chat", "chat_id", chatID, "error", err)
+		return fmt.Sprintf("чат %d", chatID)
+	}
+	switch {
+	case chat.Title != "":
+		return fmt.Sprintf("«%s» (%d)", chat.Title, chatID)
+	case chat.Username != "":
+		return fmt.Sprintf("@%s (%d)", chat.Username, chatID)
+	default:
+		return fmt.Sprintf("чат %d", chatID)
+	}
+}
+
+// handleAdminClosePoll завершает голосование без проверки владельца
+func (b *Bot) handleAdminClosePoll(c telebot.Context, args []string) error {
+	pollID, ok := adminPollID(c, "closepoll", args)
+	if !ok {
+		return nil
+	}
+
+	ctx := context.Background()
+	poll, err := b.store.GetPoll(ctx, pollID)
+	if errors.Is(err, errPollNotFound) {
+		return c.Send("❌ Голосование не найдено")
+	}
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка получения голосования")
+	}
+	if poll.IsClosed() {
+		return c.Send(fmt.Sprintf("🔒 Голосование %d уже завершено.", pollID))
+	}
+
+	if err := b.store.SetPollActive(ctx, pollID, false); err != nil {
+		b.log.Error("failed to set poll status", "poll_id", pollID, "active", false, "error", err)
+		return c.Send("❌ Ошибка изменения статуса голосования")
+	}
+	b.updateQueue.Schedule(pollID)
+
+	b.log.Info("poll closed by admin", "admin_id", c.Sender().ID, "poll_id", pollID, "owner_id", poll.CreatorID)
+	return c.Send(fmt.Sprintf("🔒 Голосование %d завершено администратором. Опубликованные сообщения будут обновлены.", pollID))
+}
+
+// handleAdminDeletePoll удаляет голосование и заменяет текст его опубликованных сообщений
+func (b *Bot) handleAdminDeletePoll(c telebot.Context, args []string) error {
+	pollID, ok := adminPollID(c, "deletepoll", args)
+	if !ok {
+		return nil
+	}
+
+	// Публикации удаляются каскадом вместе с голосованием, поэтому читаем их заранее
+	ctx := context.Background()
+	chats, err := b.store.ListPollChats(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to list poll chats", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка получения публикаций")
+	}
+
+	err = b.store.DeletePoll(ctx, pollID)
+	if errors.Is(err, errPollNotFound) {
+		return c.Send("❌ Голосование не найдено")
+	}
+	if err != nil {
+		b.log.Error("failed to delete poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка удаления голосования")
+	}
+
+	// Кнопки удаленного голосования больше не работают — воркер заменит сообщения пометкой
+	// в пределах бюджета правок; ожидающая перерисовка голосования сливается с этой
+	b.markPollDeleted(pollID, chats)
+	b.updateQueue.Schedule(pollID)
+
+	b.log.Info("poll deleted by admin", "admin_id", c.Sender().ID, "poll_id", pollID, "messages", len(chats))
+	return c.Send(fmt.Sprintf("🗑 Голосование %d удалено.\nОпубликованные сообщения (%d) будут заменены пометкой об удалении.", pollID, len(chats)))
+}
+
+// markPollDeleted запоминает публикации удаленного голосования для воркера обновлений
+func (b *Bot) markPollDeleted(pollID int64, chats []PollChat) {
+	b.deletedMu.Lock()
+	defer b.deletedMu.Unlock()
+	b.deleted[pollID] = chats
+}
+
+// takeDeletedPoll забирает публикации удаленного голосования (false — голосование не удалялось)
+func (b *Bot) takeDeletedPoll(pollID int64) ([]PollChat, bool) {
+	b.deletedMu.Lock()
+	defer b.deletedMu.Unlock()
+	chats, ok := b.deleted[pollID]
+	delete(b.deleted, pollID)
+	return chats, ok
+}
+
+// handleAdminBan блокирует пользователя или снимает блокировку.
+// Пользователь указывается Telegram ID или @username (ищется среди авторов и участников голосований).
+func (b *Bot) handleAdminBan(c telebot.Context, args []string, ban bool) error {
+	subcommand := "unban"
+	if ban {
+		subcommand = "ban"
+	}
+	if len(args) == 0 {
+		return c.Send(fmt.Sprintf("❌ Укажите пользователя.\n\nИспользование: /admin %s <ID|@username>", subcommand))
+	}
+
+	ctx := context.Background()
+	target, err := b.resolveUser(ctx, args[0])
+	if errors.Is(err, errUserNotFound) {
+		return c.Send(fmt.Sprintf("❌ Пользователь %s не найден среди авторов и участников голосований. Укажите Telegram ID.", args[0]))
+	}
+	if err != nil {
+		b.log.Error("failed to resolve user", "user", args[0], "error", err)
+		return c.Send("❌ Некорректный пользователь: укажите Telegram ID или @username")
+	}
+
+	adminID := c.Sender().ID
+	if !ban {
+		err := b.store.UnbanUser(ctx, target)
+		if err == nil || errors.Is(err, errUserNotBanned) {
+			b.setBanned(target, false)
+		}
+		if errors.Is(err, errUserNotBanned) {
+			return c.Send(fmt.Sprintf("ℹ️ Пользователь %d не заблокирован.", target))
+		}
+		if err != nil {
+			b.log.Error("failed to unban user", "user_id", target, "error", err)
+			return c.Send("❌ Ошибка снятия блокировки")
+		}
+		b.log.Info("user unbanned", "admin_id", adminID, "user_id", target)
+		return c.Send(fmt.Sprintf("✅ Блокировка пользователя %d снята.", target))
+	}
+
+	if b.isAdmin(target) {
+		return c.Send("❌ Нельзя заблокировать администратора бота.")
+	}
+	if err := b.store.BanUser(ctx, target, adminID); err != nil {
+		b.log.Error("failed to ban user", "user_id", target, "error", err)
+		return c.Send("❌ Ошибка блокировки пользователя")
+	}
+	b.setBanned(target, true)
+	// Незавершенный диалог (создание голосования, ранжирование) больше не понадобится
+	b.dialog.ResetContext(target)
+
+	b.log.Info("user banned", "admin_id", adminID, "user_id", target)
+	return c.Send(fmt.Sprintf("🚫 Пользователь %d заблокирован: он не может создавать голосования и голосовать.\n\n"+
+		"Снять блокировку: /admin unban %d", target, target))
+}
+
+// resolveUser возвращает Telegram ID по аргументу: числу или @username
+func (b *Bot) resolveUser(ctx context.Context, arg string) (int64, error) {
+	if username, ok := strings.CutPrefix(arg, "@"); ok {
+		return b.store.FindUserID(ctx, username)
+	}
+	userID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, fmt.Errorf("некорректный Telegram ID %q", arg)
+	}
+	return userID, nil
+}
@@ -3,26 +3,63 @@ package bot
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"wubrg-voting-bot/config"
+
 	"gopkg.in/telebot.v4"
 )
 
 type Bot struct {
 	bot         *telebot.Bot
-	db          *pgxpool.Pool
+	log         *slog.Logger
+	store       Store
+	inline      config.Inline      // Настройки inline-режима
+	limits      config.Limits      // Ограничения длины голосований и списков
+	rateLimit   config.RateLimit   // Лимиты частоты нажатий и правок сообщений
+	expiryEvery time.Duration      // Период проверки голосований с истекшим сроком
+	admins      map[int64]struct{} // Telegram ID администраторов (команда /admin)
+	bannedMu    sync.RWMutex
+	banned      map[int64]struct{} // Заблокированные пользователи (копия voting.banned_users)
+	banRefresh  time.Duration      // Период перечитывания banned из базы
+	deletedMu   sync.Mutex
+	deleted     map[int64][]PollChat // Публикации удаленных голосований, еще не замененные пометкой
 	dialog      *DialogManager
 	updateQueue *UpdateQueue
+	webhook     *webhookPoller // nil — обновления принимаются long polling
+	voteLimiter *VoteLimiter
+	editBudget  *editBudget
+	metrics     *Metrics
+
+	started       atomic.Bool   // Start вызван, поллер telebot нужно останавливать
+	polling       atomic.Bool   // поллер telebot принимает обновления (для /readyz)
+	workerRunning atomic.Bool   // воркер очереди обновлений работает (для /readyz)
+	stop          chan struct{} // закрывается при остановке: завершает воркер и фоновые задачи
+	stopOnce      sync.Once
+	workerDone    chan struct{} // закрывается, когда воркер очереди обновлений завершился
 }
 
-// New создает и настраивает новый экземпляр бота
-func New(token string, db *pgxpool.Pool) (*Bot, error) {
+// New создает и настраивает новый экземпляр бота по проверенным настройкам cfg.
+// Голосования хранятся в store, незавершенные диалоги — в sessions.
+// Если в cfg включен webhook, обновления принимаются через webhook, иначе — long polling.
+func New(cfg config.Config, store Store, sessions SessionStore) (*Bot, error) {
 	pref := telebot.Settings{
-		Token:  token,
-		Poller: &telebot.LongPoller{Timeout: 10 * time.Second},
+		Token:  cfg.BotToken,
+		Poller: &telebot.LongPoller{Timeout: time.Duration(cfg.PollTimeout)},
+		// Ошибки поллера (например, сбои getUpdates); ошибки обработчиков записывает handle
+		OnError: func(err error, _ telebot.Context) {
+			slog.Error("telegram error", "component", "Poller", "error", err)
+		},
+	}
+
+	var hook *webhookPoller
+	if cfg.Webhook.Enabled() {
+		hook = newWebhookPoller(cfg.Webhook)
+		pref.Poller = hook
 	}
 
 	tgBot, err := telebot.NewBot(pref)
@@ -30,53 +67,138 @@ func New(token string, db *pgxpool.Pool) (*Bot, error) {
 		return nil, fmt.Errorf("не удалось создать бота: %w", err)
 	}
 
+	if hook != nil {
+		// Порт открывается до регистрации: Telegram не должен получить адрес, который никто не слушает
+		if err := hook.listen(); err != nil {
+			return nil, err
+		}
+		if err := hook.register(tgBot); err != nil {
+			hook.ln.Close()
+			return nil, err
+		}
+	} else if err := tgBot.RemoveWebhook(); err != nil {
+		// Webhook, оставшийся от запуска в режиме webhook, не дает получать обновления через getUpdates
+		return nil, fmt.Errorf("не удалось отключить webhook: %w", err)
+	}
+
+	b := newBot(tgBot, store, sessions, cfg)
+	b.webhook = hook
+	if err := b.loadBannedUsers(context.Background()); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newBot собирает бота поверх готового клиента Telegram и регистрирует обработчики
+func newBot(tgBot *telebot.Bot, store Store, sessions SessionStore, cfg config.Config) *Bot {
 	b := &Bot{
 		bot:         tgBot,
-		db:          db,
-		dialog:      NewDialogManager(),
-		updateQueue: NewUpdateQueue(),
+		log:         slog.Default(),
+		store:       store,
+		inline:      cfg.Inline,
+		limits:      cfg.Limits,
+		rateLimit:   cfg.RateLimit,
+		expiryEvery: time.Duration(cfg.ExpiryInterval),
+		banRefresh:  time.Duration(cfg.BanRefreshInterval),
+		admins:      make(map[int64]struct{}, len(cfg.Admins)),
+		banned:      make(map[int64]struct{}),
+		deleted:     make(map[int64][]PollChat),
+		dialog:      NewDialogManager(sessions),
+		updateQueue: NewUpdateQueue(time.Duration(cfg.RenderInterval)),
+		voteLimiter: NewVoteLimiter(cfg.RateLimit),
+		editBudget:  newEditBudget(cfg.RateLimit),
+		metrics:     newMetrics(),
+		stop:        make(chan struct{}),
+		workerDone:  make(chan struct{}),
+	}
+
+	for _, id := range cfg.Admins {
+		b.admins[id] = struct{}{}
 	}
 
 	// Регистрация обработчиков
 	b.registerHandlers()
 
-	return b, nil
+	return b
 }
 
 // registerHandlers регистрирует все обработчики команд и сообщений
 func (b *Bot) registerHandlers() {
 	// Обработчик команды /start
-	b.bot.Handle("/start", b.handleStart)
+	b.handle("/start", b.handleStart)
 
 	// Обработчик команды /help
-	b.bot.Handle("/help", b.handleHelp)
+	b.handle("/help", b.handleHelp)
 
 	// Обработчик команды /status
-	b.bot.Handle("/status", b.handleStatus)
+	b.handle("/status", b.handleStatus)
 
 	// Обработчик команды /cancel - отмена диалога
-	b.bot.Handle("/cancel", b.handleCancel)
+	b.handle("/cancel", b.handleCancel)
 
 	// Обработчик команды /createpoll - создать голосование
-	b.bot.Handle("/createpoll", b.handleCreatePoll)
+	b.handle("/createpoll", b.handleCreatePoll)
 
 	// Обработчик команды /listpolls - показать список голосований
-	b.bot.Handle("/listpolls", b.handleListPolls)
+	b.handle("/listpolls", b.handleListPolls)
 
 	// Обработчик команды /publishpoll - опубликовать голосование
-	b.bot.Handle("/publishpoll", b.handlePublishPoll)
+	b.handle("/publishpoll", b.handlePublishPoll)
+
+	// Обработчики команд /closepoll и /reopenpoll - завершить и возобновить голосование
+	b.handle("/closepoll", b.handleClosePoll)
+	b.handle("/reopenpoll", b.handleReopenPoll)
+
+	// Обработчик команды /editpoll - изменить голосование после создания
+	b.handle("/editpoll", b.handleEditPoll)
+
+	// Обработчик команды /history - хронология нажатий в голосовании
+	b.handle("/history", b.handleHistory)
+
+	// Обработчик команды /export - выгрузить результаты голосования в файл
+	b.handle("/export", b.handleExport)
+
+	// Обработчик команды /admin - команды администраторов бота
+	b.handle("/admin", b.handleAdmin)
 
 	// Обработчик callback-кнопок (роутер)
-	b.bot.Handle(telebot.OnCallback, b.handleCallback)
+	b.handle(telebot.OnCallback, b.handleCallback)
 
 	// Обработчик inline-запросов
-	b.bot.Handle(telebot.OnQuery, b.handleInlineQuery)
+	b.handle(telebot.OnQuery, b.handleInlineQuery)
 
 	// Обработчик выбора inline-результата (когда пользователь отправляет голосование в чат)
-	b.bot.Handle(telebot.OnInlineResult, b.handleChosenInlineResult)
+	b.handle(telebot.OnInlineResult, b.handleChosenInlineResult)
 
 	// Обработчик текстовых сообщений (с учетом состояния диалога)
-	b.bot.Handle(telebot.OnText, b.handleText)
+	b.handle(telebot.OnText, b.handleText)
+}
+
+// handle регистрирует обработчик и записывает в лог его длительность.
+// Ошибка обработчика записывается здесь же, вместе с пользователем и чатом.
+func (b *Bot) handle(endpoint any, h telebot.HandlerFunc) {
+	// Служебные события telebot (OnText, OnCallback...) начинаются с \a
+	name := strings.TrimPrefix(fmt.Sprint(endpoint), "\a")
+	b.bot.Handle(endpoint, func(c telebot.Context) error {
+		start := time.Now()
+		err := h(c)
+		duration := time.Since(start)
+		b.metrics.handlerDuration.WithLabelValues(name).Observe(duration.Seconds())
+
+		attrs := []any{"handler", name, "duration", duration}
+		if sender := c.Sender(); sender != nil {
+			attrs = append(attrs, "user_id", sender.ID)
+		}
+		if chat := c.Chat(); chat != nil {
+			attrs = append(attrs, "chat_id", chat.ID)
+		}
+		if err != nil {
+			b.log.Error("handler failed", append(attrs, "error", err)...)
+			return nil
+		}
+		b.log.Debug("handler done", attrs...)
+		return nil
+	})
 }
 
 // handleStart обрабатывает команду /start
@@ -86,6 +208,9 @@ func (b *Bot) handleStart(c telebot.Context) error {
 	if payload == "createpoll" {
 		return b.handleCreatePoll(c)
 	}
+	if strings.HasPrefix(payload, rankStartPrefix) {
+		return b.handleRankStart(c, payload)
+	}
 
 	return c.Send("👋 Привет! Я бот для голосования WUBRG.\n\nИспользуй /help чтобы узнать доступные команды.")
 }
@@ -102,6 +227,11 @@ func (b *Bot) handleHelp(c telebot.Context) error {
 /createpoll - Создать новое голосование
 /listpolls - Показать список голосований
 /publishpoll <ID> - Опубликовать голосование
+/closepoll <ID> - Завершить голосование и показать итоги
+/reopenpoll <ID> - Возобновить завершенное голосование
+/editpoll <ID> - Изменить заголовок, описание и варианты
+/export <ID> [csv|json] [history] - Выгрузить результаты в файл
+/history <ID> - История нажатий в голосовании
 
 📲 Inline-режим:
 Используйте @bot_name в любом чате, чтобы:
@@ -109,18 +239,24 @@ func (b *Bot) handleHelp(c telebot.Context) error {
 • Поиск по названию голосования
 
 /cancel - Отменить текущий диалог`
+	if b.isAdmin(c.Sender().ID) {
+		helpText += "\n\n" + adminUsage
+	}
 	return c.Send(helpText)
 }
 
 // handleStatus обрабатывает команду /status
 func (b *Bot) handleStatus(c telebot.Context) error {
 	ctx := context.Background()
-	var result string
-	err := b.db.QueryRow(ctx, "SELECT version()").Scan(&result)
-	if err != nil {
+	if err := b.store.Ping(ctx); err != nil {
 		return c.Send("❌ Ошибка подключения к базе данных")
 	}
-	return c.Send("✅ База данных подключена и работает!")
+
+	stats := b.updateQueue.Stats()
+	return c.Send(fmt.Sprintf("✅ База данных подключена и работает!\n\n"+
+		"📨 Очередь обновлений: ждут %d, отложено %d\n"+
+		"Запросов на перерисовку: %d, слито: %d, перерисовок: %d",
+		stats.Pending, stats.Delayed, stats.Scheduled, stats.Coalesced, stats.Rendered))
 }
 
 // handleCallback роутер для callback-кнопок
@@ -131,10 +267,54 @@ func (b *Bot) handleCallback(c telebot.Context) error {
 		return b.handleVote(c)
 	case strings.HasPrefix(data, "\fpoll_done"):
 		return b.handlePollDoneCallback(c)
+	case strings.HasPrefix(data, "\fpoll_description_skip"):
+		return b.handlePollDescriptionSkipCallback(c)
+	case strings.HasPrefix(data, "\frank|"):
+		return b.handleRankButton(c)
+	case strings.HasPrefix(data, "\frank_pick|"):
+		return b.handleRankPickCallback(c)
+	case strings.HasPrefix(data, "\frank_reset"):
+		return b.handleRankResetCallback(c)
+	case strings.HasPrefix(data, "\frank_submit"):
+		return b.handleRankSubmitCallback(c)
+	case strings.HasPrefix(data, "\fpoll_mode_single"):
+		return b.handlePollModeCallback(c, VoteTypePlurality, false)
+	case strings.HasPrefix(data, "\fpoll_mode_multi"):
+		return b.handlePollModeCallback(c, VoteTypePlurality, true)
+	case strings.HasPrefix(data, "\fpoll_mode_ranked"):
+		return b.handlePollModeCallback(c, VoteTypeRanked, false)
+	case strings.HasPrefix(data, "\fpoll_max_skip"):
+		return b.handlePollMaxSelectionsSkipCallback(c)
+	case strings.HasPrefix(data, "\fpoll_anon_yes"):
+		return b.handlePollAnonymityCallback(c, true)
+	case strings.HasPrefix(data, "\fpoll_anon_no"):
+		return b.handlePollAnonymityCallback(c, false)
+	case strings.HasPrefix(data, "\fpoll_deadline_skip"):
+		return b.handlePollDeadlineSkipCallback(c)
 	case strings.HasPrefix(data, "\fpoll_confirm_yes"):
 		return b.handlePollConfirmYesCallback(c)
 	case strings.HasPrefix(data, "\fpoll_confirm_no"):
 		return b.handlePollConfirmNoCallback(c)
+	case strings.HasPrefix(data, "\fhistory|"):
+		return b.handleHistoryPageCallback(c)
+	case strings.HasPrefix(data, "\fedit_title"):
+		return b.handleEditTitleCallback(c)
+	case strings.HasPrefix(data, "\fedit_desc_clear"):
+		return b.handleEditDescriptionClearCallback(c)
+	case strings.HasPrefix(data, "\fedit_desc"):
+		return b.handleEditDescriptionCallback(c)
+	case strings.HasPrefix(data, "\fedit_rename|"):
+		return b.handleEditRenameCallback(c)
+	case strings.HasPrefix(data, "\fedit_add"):
+		return b.handleEditAddCallback(c)
+	case strings.HasPrefix(data, "\fedit_remove_menu"):
+		return b.handleEditRemoveMenuCallback(c)
+	case strings.HasPrefix(data, "\fedit_remove|"):
+		return b.handleEditRemoveCallback(c)
+	case strings.HasPrefix(data, "\fedit_back"):
+		return b.handleEditBackCallback(c)
+	case strings.HasPrefix(data, "\fedit_done"):
+		return b.handleEditDoneCallback(c)
 	default:
 		return c.Respond(&telebot.CallbackResponse{Text: "❌ Неизвестная команда"})
 	}
@@ -149,8 +329,22 @@ func (b *Bot) handleText(c telebot.Context) error {
 	switch ctx.State {
 	case StateCreatePollTitle:
 		return b.handlePollTitleInput(c)
+	case StateCreatePollDescription:
+		return b.handlePollDescriptionInput(c)
 	case StateCreatePollOption:
 		return b.handlePollOptionInput(c)
+	case StateCreatePollMaxSelections:
+		return b.handlePollMaxSelectionsInput(c)
+	case StateCreatePollDeadline:
+		return b.handlePollDeadlineInput(c)
+	case StateEditPollTitle:
+		return b.handleEditTitleInput(c)
+	case StateEditPollDescription:
+		return b.handleEditDescriptionInput(c)
+	case StateEditPollOption:
+		return b.handleEditRenameInput(c)
+	case StateEditPollAddOption:
+		return b.handleEditAddInput(c)
 	default:
 		// Обычный режим без диалога
 		return c.Send(fmt.Sprintf("Вы написали: %s\n\nИспользуйте /help для списка команд", c.Text()))
@@ -170,22 +364,75 @@ func (b *Bot) handleCancel(c telebot.Context) error {
 	return c.Send("✅ Диалог отменен. Вы вернулись в обычный режим.")
 }
 
-// startUpdateWorker запускает горутину-воркер для обработки очереди обновлений
+// startUpdateWorker запускает горутину-воркер для обработки очереди обновлений.
+// Воркер завершается после закрытия b.stop, дообработав текущее голосование.
 func (b *Bot) startUpdateWorker() {
+	log := b.log.With("component", "UpdateWorker")
+	b.workerRunning.Store(true)
 	go func() {
-		log.Println("📨 [UpdateWorker] Воркер обновления сообщений запущен")
-		for range b.updateQueue.notify {
-			polls := b.updateQueue.drain()
-			for _, pollID := range polls {
-				b.updatePollMessages(pollID)
+		defer close(b.workerDone)
+		defer b.workerRunning.Store(false)
+		log.Info("update worker started")
+		for {
+			select {
+			case <-b.stop:
+				log.Info("update worker stopped")
+				return
+			case <-b.updateQueue.notify:
+				polls := b.updateQueue.drain()
+				for _, pollID := range polls {
+					b.updatePollMessages(pollID)
+				}
 			}
 		}
 	}()
 }
 
-// Start запускает бота
+// Start запускает фоновые задачи и прием обновлений от Telegram и сразу возвращает управление.
+// Бот считается запущенным уже к возврату из Start, поэтому Stop, вызванный сразу
+// после него (например, по раннему сигналу), остановит и поллер, и воркер.
 func (b *Bot) Start() {
-	log.Println("🤖 Бот начал прослушивание сообщений...")
+	b.log.Info("bot started", "webhook", b.webhook != nil)
+	b.started.Store(true)
 	b.startUpdateWorker()
-	b.bot.Start()
+	b.startExpiryScheduler()
+	b.startBanRefresh()
+	b.dialog.startCleanup(sessionCleanupInterval, b.stop)
+	go func() {
+		b.polling.Store(true)
+		defer b.polling.Store(false)
+		b.bot.Start()
+	}()
+}
+
+// Stop останавливает бота: перестает принимать обновления от Telegram, завершает
+// фоновые задачи и перерисовывает голосования, оставшиеся в очереди обновлений.
+// Если ctx истекает раньше, оставшиеся голосования не перерисовываются и возвращается ошибка.
+func (b *Bot) Stop(ctx context.Context) error {
+	started := b.started.Load()
+	if started {
+		b.polling.Store(false)
+		b.bot.Stop()
+		b.log.Info("stopped receiving updates")
+	}
+
+	b.stopOnce.Do(func() { close(b.stop) })
+	if started {
+		select {
+		case <-b.workerDone:
+		case <-ctx.Done():
+			return fmt.Errorf("воркер обновления сообщений не завершился: %w", ctx.Err())
+		}
+	}
+
+	// Голосования из очереди перерисовываем сразу, не дожидаясь интервала и таймеров
+	polls := b.updateQueue.close()
+	b.log.Info("rendering queued polls before shutdown", "component", "UpdateWorker", "polls", len(polls))
+	for i, pollID := range polls {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("не перерисовано голосований: %d: %w", len(polls)-i, err)
+		}
+		b.updatePollMessages(pollID)
+	}
+	return nil
 }
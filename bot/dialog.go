@@ -1,108 +1,120 @@
 package bot
 
 import (
+	"context"
+	"log/slog"
 	"sync"
+	"time"
 )
 
 // State представляет состояние диалога с пользователем
 type State string
 
 const (
-	StateIdle              State = "idle"                // Ожидание
-	StateCreatePollTitle   State = "create_poll_title"   // Создание голосования: ввод заголовка
-	StateCreatePollOption  State = "create_poll_option"  // Создание голосования: ввод варианта
-	StateCreatePollConfirm State = "create_poll_confirm" // Создание голосования: подтверждение
+	StateIdle                    State = "idle"                       // Ожидание
+	StateCreatePollTitle         State = "create_poll_title"          // Создание голосования: ввод заголовка
+	StateCreatePollDescription   State = "create_poll_description"    // Создание голосования: ввод описания (необязательно)
+	StateCreatePollOption        State = "create_poll_option"         // Создание голосования: ввод варианта
+	StateCreatePollMode          State = "create_poll_mode"           // Создание голосования: выбор режима (один/несколько вариантов)
+	StateCreatePollMaxSelections State = "create_poll_max_selections" // Создание голосования: ввод лимита выбранных вариантов
+	StateCreatePollAnonymity     State = "create_poll_anonymity"      // Создание голосования: выбор видимости голосов
+	StateCreatePollDeadline      State = "create_poll_deadline"       // Создание голосования: ввод срока окончания
+	StateCreatePollConfirm       State = "create_poll_confirm"        // Создание голосования: подтверждение
+	StateRankPoll                State = "rank_poll"                  // Ранжирование вариантов рейтингового голосования
+	StateEditPoll                State = "edit_poll"                  // Редактирование голосования: меню
+	StateEditPollTitle           State = "edit_poll_title"            // Редактирование голосования: ввод заголовка
+	StateEditPollDescription     State = "edit_poll_description"      // Редактирование голосования: ввод описания
+	StateEditPollOption          State = "edit_poll_option"           // Редактирование голосования: ввод нового текста варианта
+	StateEditPollAddOption       State = "edit_poll_add_option"       // Редактирование голосования: ввод нового варианта
 )
 
+// DialogData типизированные данные диалога, сохраняемые в хранилище сессий в JSON
+type DialogData struct {
+	Draft      PollDraft `json:"draft"`                  // Черновик голосования мастера /createpoll
+	RankPollID int64     `json:"rank_poll_id,omitempty"` // Голосование, которое пользователь ранжирует
+	RankOrder  []int64   `json:"rank_order,omitempty"`   // Текущее ранжирование (optionID по порядку)
+
+	EditPollID   int64 `json:"edit_poll_id,omitempty"`   // Голосование, которое редактирует владелец
+	EditOptionID int64 `json:"edit_option_id,omitempty"` // Вариант, текст которого редактируется
+}
+
 // DialogContext хранит контекст диалога пользователя
 type DialogContext struct {
-	State State                  // Текущее состояние
-	Data  map[string]interface{} // Данные диалога
+	State State      `json:"state"` // Текущее состояние
+	Data  DialogData `json:"data"`  // Данные диалога
+}
+
+// newIdleContext создает пустой контекст в состоянии ожидания
+func newIdleContext() *DialogContext {
+	return &DialogContext{State: StateIdle}
 }
 
-// DialogManager управляет состояниями диалогов пользователей
+// DialogManager управляет состояниями диалогов пользователей.
+// Контексты хранятся в SessionStore, поэтому реализация хранилища определяет,
+// переживут ли незавершенные диалоги перезапуск бота.
 type DialogManager struct {
-	mu       sync.RWMutex
-	sessions map[int64]*DialogContext // userID -> контекст диалога
+	mu    sync.Mutex // сериализует чтение-изменение-запись контекстов в пределах процесса
+	store SessionStore
+	log   *slog.Logger
 }
 
-// NewDialogManager создает новый менеджер диалогов
-func NewDialogManager() *DialogManager {
+// NewDialogManager создает новый менеджер диалогов поверх хранилища сессий
+func NewDialogManager(store SessionStore) *DialogManager {
 	return &DialogManager{
-		sessions: make(map[int64]*DialogContext),
+		store: store,
+		log:   slog.Default().With("component", "Dialog"),
 	}
 }
 
-// GetContext получает контекст диалога пользователя
+// GetContext получает контекст диалога пользователя.
+// Если сессии нет (или хранилище недоступно), возвращается контекст в состоянии ожидания.
 func (dm *DialogManager) GetContext(userID int64) *DialogContext {
-	dm.mu.RLock()
-	defer dm.mu.RUnlock()
-
-	ctx, exists := dm.sessions[userID]
+	ctx, exists, err := dm.store.Load(context.Background(), userID)
+	if err != nil {
+		dm.log.Error("failed to load session", "user_id", userID, "error", err)
+		return newIdleContext()
+	}
 	if !exists {
-		return &DialogContext{
-			State: StateIdle,
-			Data:  make(map[string]interface{}),
-		}
+		return newIdleContext()
 	}
 	return ctx
 }
 
-// SetState устанавливает состояние диалога для пользователя
-func (dm *DialogManager) SetState(userID int64, state State) {
+// Update изменяет контекст диалога пользователя и сохраняет его в хранилище
+func (dm *DialogManager) Update(userID int64, fn func(ctx *DialogContext)) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
-	ctx, exists := dm.sessions[userID]
-	if !exists {
-		ctx = &DialogContext{
-			State: state,
-			Data:  make(map[string]interface{}),
-		}
-		dm.sessions[userID] = ctx
-	} else {
-		ctx.State = state
+	ctx := dm.GetContext(userID)
+	fn(ctx)
+
+	if err := dm.store.Save(context.Background(), userID, ctx); err != nil {
+		dm.log.Error("failed to save session", "user_id", userID, "error", err)
 	}
 }
 
-// SetData сохраняет данные в контекст диалога
-func (dm *DialogManager) SetData(userID int64, key string, value interface{}) {
-	dm.mu.Lock()
-	defer dm.mu.Unlock()
+// SetState устанавливает состояние диалога для пользователя
+func (dm *DialogManager) SetState(userID int64, state State) {
+	dm.Update(userID, func(ctx *DialogContext) {
+		ctx.State = state
+	})
+}
 
-	ctx, exists := dm.sessions[userID]
-	if !exists {
-		ctx = &DialogContext{
-			State: StateIdle,
-			Data:  make(map[string]interface{}),
-		}
-		dm.sessions[userID] = ctx
-	}
-	ctx.Data[key] = value
+// UpdateData изменяет данные диалога пользователя
+func (dm *DialogManager) UpdateData(userID int64, fn func(data *DialogData)) {
+	dm.Update(userID, func(ctx *DialogContext) {
+		fn(&ctx.Data)
+	})
 }
 
 // GetData получает данные из контекста диалога
-func (dm *DialogManager) GetData(userID int64, key string) (interface{}, bool) {
-	dm.mu.RLock()
-	defer dm.mu.RUnlock()
-
-	ctx, exists := dm.sessions[userID]
-	if !exists {
-		return nil, false
-	}
-	value, ok := ctx.Data[key]
-	return value, ok
+func (dm *DialogManager) GetData(userID int64) DialogData {
+	return dm.GetContext(userID).Data
 }
 
 // ResetContext сбрасывает контекст диалога пользователя
 func (dm *DialogManager) ResetContext(userID int64) {
-	dm.mu.Lock()
-	defer dm.mu.Unlock()
-
-	dm.sessions[userID] = &DialogContext{
-		State: StateIdle,
-		Data:  make(map[string]interface{}),
-	}
+	dm.DeleteContext(userID)
 }
 
 // DeleteContext удаляет контекст диалога пользователя
@@ -110,13 +122,42 @@ func (dm *DialogManager) DeleteContext(userID int64) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
-	delete(dm.sessions, userID)
+	if err := dm.store.Delete(context.Background(), userID); err != nil {
+		dm.log.Error("failed to delete session", "user_id", userID, "error", err)
+	}
 }
 
 // GetAllSessions возвращает количество активных сессий
 func (dm *DialogManager) GetAllSessions() int {
-	dm.mu.RLock()
-	defer dm.mu.RUnlock()
+	count, err := dm.store.Count(context.Background())
+	if err != nil {
+		dm.log.Error("failed to count sessions", "error", err)
+		return 0
+	}
+	return count
+}
 
-	return len(dm.sessions)
+// startCleanup запускает горутину, периодически удаляющую просроченные сессии,
+// до закрытия stop
+func (dm *DialogManager) startCleanup(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			removed, err := dm.store.DeleteExpired(context.Background())
+			if err != nil {
+				dm.log.Error("failed to delete expired sessions", "error", err)
+				continue
+			}
+			if removed > 0 {
+				dm.log.Info("expired sessions deleted", "sessions", removed)
+			}
+		}
+	}()
 }
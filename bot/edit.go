@@ -0,0 +1,375 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v4"
+)
+
+// handleEditPoll запускает диалог редактирования голосования (только владелец)
+func (b *Bot) handleEditPoll(c telebot.Context) error {
+	pollID, ok, err := parsePollIDArg(c.Text())
+	if !ok {
+		return c.Send("❌ Укажите ID голосования.\n\nИспользование: /editpoll <ID>\n\nПосмотрите список голосований: /listpolls")
+	}
+	if err != nil {
+		return c.Send("❌ Некорректный ID голосования")
+	}
+
+	// Новый текст вводится обычными сообщениями, а в группах бот их не видит
+	if c.Chat().Type != telebot.ChatPrivate {
+		return c.Send("❌ Редактирование доступно только в личном чате с ботом.")
+	}
+
+	userID := c.Sender().ID
+	poll, err := b.store.GetPoll(context.Background(), pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Голосование не найдено")
+	}
+	if poll.CreatorID != userID {
+		b.log.Warn("edit of foreign poll denied", "user_id", userID, "poll_id", pollID, "owner_id", poll.CreatorID)
+		return c.Send("❌ Вы можете изменять только свои голосования.")
+	}
+
+	b.dialog.Update(userID, func(ctx *DialogContext) {
+		ctx.State = StateEditPoll
+		ctx.Data = DialogData{EditPollID: pollID}
+	})
+	return b.sendEditMenu(c, poll, "")
+}
+
+// editingPoll возвращает редактируемое голосование, если диалог пользователя в одном из состояний states
+func (b *Bot) editingPoll(c telebot.Context, states ...State) (*PollData, bool) {
+	dialogCtx := b.dialog.GetContext(c.Sender().ID)
+	if dialogCtx.Data.EditPollID == 0 || !slices.Contains(states, dialogCtx.State) {
+		return nil, false
+	}
+	pollID := dialogCtx.Data.EditPollID
+
+	poll, err := b.store.GetPoll(context.Background(), pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return nil, false
+	}
+	// Владелец проверялся при старте диалога, но голосование могли удалить и создать заново
+	if poll.CreatorID != c.Sender().ID {
+		return nil, false
+	}
+	return poll, true
+}
+
+// editStates все состояния диалога редактирования
+var editStates = []State{StateEditPoll, StateEditPollTitle, StateEditPollDescription, StateEditPollOption, StateEditPollAddOption}
+
+// sendEditMenu показывает текущее состояние голосования и кнопки редактирования
+func (b *Bot) sendEditMenu(c telebot.Context, poll *PollData, status string) error {
+	b.dialog.SetState(c.Sender().ID, StateEditPoll)
+	text, markup := formatEditMenu(poll)
+	if status != "" {
+		text = status + "\n\n" + text
+	}
+	return c.Send(text, markup)
+}
+
+// formatEditMenu форматирует меню редактирования голосования
+func formatEditMenu(poll *PollData) (string, *telebot.ReplyMarkup) {
+	text := fmt.Sprintf("✏️ Редактирование голосования %d\n\n📝 %s\n", poll.ID, poll.Title)
+	if poll.Description != "" {
+		text += poll.Description + "\n"
+	}
+	text += "\n"
+	for i, opt := range poll.Options {
+		text += fmt.Sprintf("%d. %s (%d)\n", i+1, opt.Text, optionVoteCount(poll, opt.ID))
+	}
+	text += "\nВыберите, что изменить:"
+
+	markup := &telebot.ReplyMarkup{}
+	rows := []telebot.Row{
+		markup.Row(markup.Data("✏️ Заголовок", "edit_title"), markup.Data("📝 Описание", "edit_desc")),
+	}
+	for i, opt := range poll.Options {
+		label := fmt.Sprintf("✏️ %d. %s", i+1, opt.Text)
+		rows = append(rows, markup.Row(markup.Data(label, "edit_rename", strconv.FormatInt(opt.ID, 10))))
+	}
+	rows = append(rows,
+		markup.Row(markup.Data("➕ Добавить вариант", "edit_add"), markup.Data("🗑 Удалить вариант", "edit_remove_menu")),
+		markup.Row(markup.Data("✅ Готово", "edit_done")),
+	)
+	markup.Inline(rows...)
+	return text, markup
+}
+
+// optionVoteCount возвращает число голосов за вариант (для рейтингового — число бюллетеней с ним)
+func optionVoteCount(poll *PollData, optionID int64) int {
+	if poll.VoteType == VoteTypeRanked {
+		count := 0
+		for _, ballot := range poll.Ballots {
+			if slices.Contains(ballot, optionID) {
+				count++
+			}
+		}
+		return count
+	}
+	for _, opt := range poll.Options {
+		if opt.ID == optionID {
+			return len(opt.Votes)
+		}
+	}
+	return 0
+}
+
+// handleEditTitleCallback просит ввести новый заголовок
+func (b *Bot) handleEditTitleCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	b.dialog.SetState(c.Sender().ID, StateEditPollTitle)
+	c.Respond(&telebot.CallbackResponse{})
+	return c.Send(fmt.Sprintf("Текущий заголовок: %s\n\nВведите новый заголовок:", poll.Title))
+}
+
+// handleEditDescriptionCallback просит ввести новое описание
+func (b *Bot) handleEditDescriptionCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	b.dialog.SetState(c.Sender().ID, StateEditPollDescription)
+	c.Respond(&telebot.CallbackResponse{})
+
+	current := "нет"
+	markup := &telebot.ReplyMarkup{}
+	if poll.Description != "" {
+		current = poll.Description
+		markup.Inline(markup.Row(markup.Data("🗑 Убрать описание", "edit_desc_clear")))
+	}
+	return c.Send(fmt.Sprintf("Текущее описание: %s\n\nВведите новое описание:", current), markup)
+}
+
+// handleEditDescriptionClearCallback убирает описание голосования
+func (b *Bot) handleEditDescriptionClearCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, StateEditPollDescription)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	c.Respond(&telebot.CallbackResponse{})
+	return b.savePollText(c, poll, poll.Title, "", "✅ Описание убрано")
+}
+
+// handleEditTitleInput сохраняет новый заголовок
+func (b *Bot) handleEditTitleInput(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, StateEditPollTitle)
+	if !ok {
+		return c.Send("❌ Нет активного редактирования голосования")
+	}
+
+	title := c.Text()
+	if err := validatePollTitle(title, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err))
+	}
+	return b.savePollText(c, poll, title, poll.Description, "✅ Заголовок изменен")
+}
+
+// handleEditDescriptionInput сохраняет новое описание
+func (b *Bot) handleEditDescriptionInput(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, StateEditPollDescription)
+	if !ok {
+		return c.Send("❌ Нет активного редактирования голосования")
+	}
+
+	description := strings.TrimSpace(c.Text())
+	if err := validatePollDescription(description, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err))
+	}
+	return b.savePollText(c, poll, poll.Title, description, "✅ Описание изменено")
+}
+
+// savePollText сохраняет заголовок и описание и обновляет опубликованные копии
+func (b *Bot) savePollText(c telebot.Context, poll *PollData, title, description, status string) error {
+	if err := b.store.UpdatePollText(context.Background(), poll.ID, title, description); err != nil {
+		b.log.Error("failed to update poll text", "poll_id", poll.ID, "error", err)
+		return c.Send("❌ Ошибка сохранения изменений")
+	}
+	b.log.Info("poll text updated", "user_id", c.Sender().ID, "poll_id", poll.ID)
+	return b.afterPollEdit(c, poll.ID, status)
+}
+
+// handleEditRenameCallback просит ввести новый текст варианта
+func (b *Bot) handleEditRenameCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	optionID, err := strconv.ParseInt(strings.TrimPrefix(c.Data(), "\fedit_rename|"), 10, 64)
+	if err != nil || !pollHasOption(poll, optionID) {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Вариант не найден"})
+	}
+
+	b.dialog.Update(c.Sender().ID, func(ctx *DialogContext) {
+		ctx.State = StateEditPollOption
+		ctx.Data.EditOptionID = optionID
+	})
+	c.Respond(&telebot.CallbackResponse{})
+	return c.Send(fmt.Sprintf("Текущий текст: %s\n\nВведите новый текст варианта:", pollOptionText(poll, optionID)))
+}
+
+// handleEditRenameInput сохраняет новый текст варианта
+func (b *Bot) handleEditRenameInput(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, StateEditPollOption)
+	if !ok {
+		return c.Send("❌ Нет активного редактирования голосования")
+	}
+
+	text := c.Text()
+	if err := validatePollOption(text, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err))
+	}
+
+	optionID := b.dialog.GetData(c.Sender().ID).EditOptionID
+	err := b.store.RenameOption(context.Background(), poll.ID, optionID, text)
+	if errors.Is(err, errOptionNotFound) {
+		return b.sendEditMenu(c, poll, "❌ Вариант уже удален")
+	}
+	if err != nil {
+		b.log.Error("failed to rename option", "poll_id", poll.ID, "option_id", optionID, "error", err)
+		return c.Send("❌ Ошибка сохранения изменений")
+	}
+
+	b.log.Info("option renamed", "user_id", c.Sender().ID, "poll_id", poll.ID, "option_id", optionID)
+	return b.afterPollEdit(c, poll.ID, "✅ Вариант изменен")
+}
+
+// handleEditAddCallback просит ввести новый вариант
+func (b *Bot) handleEditAddCallback(c telebot.Context) error {
+	if _, ok := b.editingPoll(c, editStates...); !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	b.dialog.SetState(c.Sender().ID, StateEditPollAddOption)
+	c.Respond(&telebot.CallbackResponse{})
+	return c.Send("Введите текст нового варианта:")
+}
+
+// handleEditAddInput добавляет вариант в голосование
+func (b *Bot) handleEditAddInput(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, StateEditPollAddOption)
+	if !ok {
+		return c.Send("❌ Нет активного редактирования голосования")
+	}
+
+	text := c.Text()
+	if err := validatePollOption(text, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err))
+	}
+
+	optionID, err := b.store.AddOption(context.Background(), poll.ID, text)
+	if err != nil {
+		b.log.Error("failed to add option", "poll_id", poll.ID, "error", err)
+		return c.Send("❌ Ошибка сохранения изменений")
+	}
+
+	b.log.Info("option added", "user_id", c.Sender().ID, "poll_id", poll.ID, "option_id", optionID)
+	return b.afterPollEdit(c, poll.ID, "✅ Вариант добавлен")
+}
+
+// handleEditRemoveMenuCallback показывает варианты, которые можно удалить
+func (b *Bot) handleEditRemoveMenuCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	b.dialog.SetState(c.Sender().ID, StateEditPoll)
+	c.Respond(&telebot.CallbackResponse{})
+
+	markup := &telebot.ReplyMarkup{}
+	rows := make([]telebot.Row, 0, len(poll.Options)+1)
+	for _, opt := range poll.Options {
+		label := "🗑 " + opt.Text
+		if votes := optionVoteCount(poll, opt.ID); votes > 0 {
+			label = fmt.Sprintf("🔒 %s (%d)", opt.Text, votes)
+		}
+		rows = append(rows, markup.Row(markup.Data(label, "edit_remove", strconv.FormatInt(opt.ID, 10))))
+	}
+	rows = append(rows, markup.Row(markup.Data("↩️ Назад", "edit_back")))
+	markup.Inline(rows...)
+
+	return c.Send("Какой вариант удалить?\n\nВарианты с голосами (🔒) удалить нельзя.", markup)
+}
+
+// handleEditRemoveCallback удаляет вариант без голосов
+func (b *Bot) handleEditRemoveCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	optionID, err := strconv.ParseInt(strings.TrimPrefix(c.Data(), "\fedit_remove|"), 10, 64)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Вариант не найден"})
+	}
+
+	err = b.store.DeleteOption(context.Background(), poll.ID, optionID)
+	switch {
+	case errors.Is(err, errOptionNotFound):
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Вариант не найден"})
+	case errors.Is(err, errOptionHasVotes):
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ За этот вариант уже голосовали, его нельзя удалить.", ShowAlert: true})
+	case errors.Is(err, errTooFewOptions):
+		return c.Respond(&telebot.CallbackResponse{Text: "⚠️ В голосовании должно остаться минимум 2 варианта.", ShowAlert: true})
+	case err != nil:
+		b.log.Error("failed to remove option", "poll_id", poll.ID, "option_id", optionID, "error", err)
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка сохранения изменений"})
+	}
+
+	b.log.Info("option removed", "user_id", c.Sender().ID, "poll_id", poll.ID, "option_id", optionID)
+	c.Respond(&telebot.CallbackResponse{Text: "🗑 Вариант удален"})
+	return b.afterPollEdit(c, poll.ID, "✅ Вариант удален")
+}
+
+// handleEditBackCallback возвращает к меню редактирования
+func (b *Bot) handleEditBackCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	c.Respond(&telebot.CallbackResponse{})
+	return b.sendEditMenu(c, poll, "")
+}
+
+// handleEditDoneCallback завершает редактирование
+func (b *Bot) handleEditDoneCallback(c telebot.Context) error {
+	poll, ok := b.editingPoll(c, editStates...)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного редактирования голосования"})
+	}
+
+	b.dialog.ResetContext(c.Sender().ID)
+	c.Respond(&telebot.CallbackResponse{Text: "✅ Готово"})
+	return c.Send(fmt.Sprintf("✅ Редактирование голосования %d завершено.\n\n%s", poll.ID, formatPollMessage(poll)))
+}
+
+// afterPollEdit ставит публикации голосования в очередь на перерисовку и показывает меню заново
+func (b *Bot) afterPollEdit(c telebot.Context, pollID int64, status string) error {
+	b.updateQueue.Schedule(pollID)
+
+	poll, err := b.store.GetPoll(context.Background(), pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		b.dialog.ResetContext(c.Sender().ID)
+		return c.Send(status)
+	}
+	return b.sendEditMenu(c, poll, status)
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deadlineLayout формат даты и времени окончания голосования (ввод и отображение)
+const deadlineLayout = "02.01.2006 15:04"
+
+// parseDeadline разбирает срок окончания голосования.
+// Поддерживаются относительные значения (30m, 2h, 3d) и абсолютная дата в формате deadlineLayout.
+func parseDeadline(input string, now time.Time) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, errors.New("срок не указан")
+	}
+
+	var deadline time.Time
+	if strings.HasSuffix(input, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(input, "d"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("не удалось распознать срок «%s»", input)
+		}
+		deadline = now.AddDate(0, 0, days)
+	} else if d, err := time.ParseDuration(input); err == nil {
+		deadline = now.Add(d)
+	} else if t, err := time.ParseInLocation(deadlineLayout, input, time.Local); err == nil {
+		deadline = t
+	} else {
+		return time.Time{}, fmt.Errorf("не удалось распознать срок «%s»", input)
+	}
+
+	if !deadline.After(now) {
+		return time.Time{}, errors.New("срок окончания должен быть в будущем")
+	}
+
+	return deadline, nil
+}
+
+// startExpiryScheduler запускает горутину, которая периодически закрывает голосования с истекшим сроком
+func (b *Bot) startExpiryScheduler() {
+	go func() {
+		b.log.Info("expiry scheduler started", "component", "ExpiryScheduler")
+		ticker := time.NewTicker(b.expiryEvery)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.closeExpiredPolls()
+			}
+		}
+	}()
+}
+
+// closeExpiredPolls переводит голосования с истекшим сроком в неактивные
+// и ставит их в очередь на перерисовку опубликованных сообщений.
+func (b *Bot) closeExpiredPolls() {
+	ctx := context.Background()
+	log := b.log.With("component", "ExpiryScheduler")
+
+	closed, err := b.store.CloseExpiredPolls(ctx)
+	if err != nil {
+		log.Error("failed to close expired polls", "error", err)
+		return
+	}
+
+	for _, pollID := range closed {
+		log.Info("poll closed by deadline", "poll_id", pollID)
+		b.updateQueue.Schedule(pollID)
+	}
+}
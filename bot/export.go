@@ -0,0 +1,263 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+// Форматы выгрузки /export
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+
+	// exportHistoryFlag аргумент /export, добавляющий в выгрузку историю нажатий из vote_log
+	exportHistoryFlag = "history"
+)
+
+// exportUsage подсказка по команде /export
+const exportUsage = "Использование: /export <ID> [csv|json] [history]\n\n" +
+	"csv — таблица (по умолчанию), json — структурированный файл,\n" +
+	"history — добавить историю всех нажатий на кнопки."
+
+// pollExport выгрузка голосования: итоги по вариантам, голоса и (опционально) история нажатий
+type pollExport struct {
+	PollID      int64               `json:"poll_id"`
+	Title       string              `json:"title"`
+	VoteType    string              `json:"vote_type"`
+	IsAnonymous bool                `json:"is_anonymous"`
+	IsClosed    bool                `json:"is_closed"`
+	ExportedAt  time.Time           `json:"exported_at"`
+	Tallies     []exportTally       `json:"tallies"`
+	Votes       []exportVote        `json:"votes"`
+	History     []exportHistoryItem `json:"history,omitempty"`
+}
+
+// exportTally итог по варианту (в рейтинговом голосовании — первые предпочтения)
+type exportTally struct {
+	OptionID int64  `json:"option_id"`
+	Option   string `json:"option"`
+	Votes    int    `json:"votes"`
+}
+
+// exportVote голос пользователя. В анонимном голосовании данные пользователя не выгружаются.
+type exportVote struct {
+	UserID   int64     `json:"user_id,omitempty"`
+	Username string    `json:"username,omitempty"`
+	OptionID int64     `json:"option_id"`
+	Option   string    `json:"option"`
+	Rank     int       `json:"rank,omitempty"`
+	VotedAt  time.Time `json:"voted_at"`
+}
+
+// exportHistoryItem запись истории нажатий
+type exportHistoryItem struct {
+	UserID    int64     `json:"user_id,omitempty"`
+	OptionID  int64     `json:"option_id"`
+	Option    string    `json:"option"`
+	Action    string    `json:"action,omitempty"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+// handleExport отправляет владельцу файл с результатами голосования
+func (b *Bot) handleExport(c telebot.Context) error {
+	args := strings.Fields(c.Text())
+	if len(args) < 2 {
+		return c.Send("❌ Укажите ID голосования.\n\n" + exportUsage)
+	}
+	pollID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return c.Send("❌ Некорректный ID голосования")
+	}
+
+	format := exportFormatCSV
+	withHistory := false
+	for _, arg := range args[2:] {
+		switch strings.ToLower(arg) {
+		case exportFormatCSV, exportFormatJSON:
+			format = strings.ToLower(arg)
+		case exportHistoryFlag:
+			withHistory = true
+		default:
+			return c.Send(fmt.Sprintf("❌ Неизвестный параметр %q.\n\n%s", arg, exportUsage))
+		}
+	}
+
+	// Выгрузка содержит данные проголосовавших, поэтому отправляется только в личный чат
+	if c.Chat().Type != telebot.ChatPrivate {
+		return c.Send("❌ Экспорт доступен только в личном чате с ботом.")
+	}
+
+	ctx := context.Background()
+	userID := c.Sender().ID
+
+	poll, err := b.store.GetPoll(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Голосование не найдено")
+	}
+	if poll.CreatorID != userID {
+		b.log.Warn("export of foreign poll denied", "user_id", userID, "poll_id", pollID, "owner_id", poll.CreatorID)
+		return c.Send("❌ Вы можете выгружать только свои голосования.")
+	}
+
+	export, err := b.buildPollExport(ctx, poll, withHistory)
+	if err != nil {
+		b.log.Error("failed to prepare export", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка подготовки выгрузки")
+	}
+
+	var content []byte
+	mime := "text/csv"
+	if format == exportFormatJSON {
+		content, err = json.MarshalIndent(export, "", "  ")
+		mime = "application/json"
+	} else {
+		content, err = export.encodeCSV()
+	}
+	if err != nil {
+		b.log.Error("failed to render export file", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка подготовки выгрузки")
+	}
+
+	doc := &telebot.Document{
+		File:     telebot.FromReader(bytes.NewReader(content)),
+		FileName: fmt.Sprintf("poll_%d.%s", pollID, format),
+		MIME:     mime,
+		Caption:  fmt.Sprintf("📤 %s\nГолосов: %d", poll.Title, len(export.Votes)),
+	}
+	if err := c.Send(doc); err != nil {
+		b.log.Error("failed to send export", "poll_id", pollID, "error", err)
+		return c.Send("❌ Ошибка отправки файла")
+	}
+
+	b.log.Info("poll exported", "user_id", userID, "poll_id", pollID, "format", format, "history", withHistory)
+	return nil
+}
+
+// buildPollExport собирает выгрузку голосования из хранилища
+func (b *Bot) buildPollExport(ctx context.Context, poll *PollData, withHistory bool) (*pollExport, error) {
+	export := &pollExport{
+		PollID:      poll.ID,
+		Title:       poll.Title,
+		VoteType:    poll.VoteType,
+		IsAnonymous: poll.IsAnonymous,
+		IsClosed:    poll.IsClosed(),
+		ExportedAt:  time.Now().UTC(),
+		Tallies:     make([]exportTally, 0, len(poll.Options)),
+	}
+
+	// В рейтинговом голосовании итог по варианту — число первых предпочтений
+	firstChoices := make(map[int64]int)
+	for _, ballot := range poll.Ballots {
+		if len(ballot) > 0 {
+			firstChoices[ballot[0]]++
+		}
+	}
+	for _, opt := range poll.Options {
+		votes := len(opt.Votes)
+		if poll.VoteType == VoteTypeRanked {
+			votes = firstChoices[opt.ID]
+		}
+		export.Tallies = append(export.Tallies, exportTally{OptionID: opt.ID, Option: opt.Text, Votes: votes})
+	}
+
+	records, err := b.store.ListVoteRecords(ctx, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Votes = make([]exportVote, 0, len(records))
+	for _, record := range records {
+		vote := exportVote{
+			OptionID: record.OptionID,
+			Option:   pollOptionText(poll, record.OptionID),
+			Rank:     record.Rank,
+			VotedAt:  record.VotedAt.UTC(),
+		}
+		if !poll.IsAnonymous {
+			vote.UserID = record.Voter.UserID
+			vote.Username = record.Voter.Username
+		}
+		export.Votes = append(export.Votes, vote)
+	}
+
+	if !withHistory {
+		return export, nil
+	}
+
+	entries, err := b.store.ListVoteLog(ctx, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.History = make([]exportHistoryItem, 0, len(entries))
+	for _, entry := range entries {
+		item := exportHistoryItem{
+			OptionID:  entry.OptionID,
+			Option:    pollOptionText(poll, entry.OptionID),
+			Action:    entry.Action,
+			ClickedAt: entry.ClickedAt.UTC(),
+		}
+		if !poll.IsAnonymous {
+			item.UserID = entry.UserID
+		}
+		export.History = append(export.History, item)
+	}
+	return export, nil
+}
+
+// encodeCSV форматирует выгрузку как CSV: разделы с итогами, голосами и историей
+// идут друг за другом через пустую строку, у каждого раздела свой заголовок
+func (e *pollExport) encodeCSV() ([]byte, error) {
+	var buf bytes.Buffer
+	// BOM, чтобы Excel открыл кириллицу в UTF-8 без ручного выбора кодировки
+	buf.WriteString("\ufeff")
+
+	w := csv.NewWriter(&buf)
+	formatUserID := func(userID int64) string {
+		if userID == 0 {
+			return ""
+		}
+		return strconv.FormatInt(userID, 10)
+	}
+
+	records := [][]string{{"option_id", "option", "votes"}}
+	for _, tally := range e.Tallies {
+		records = append(records, []string{strconv.FormatInt(tally.OptionID, 10), tally.Option, strconv.Itoa(tally.Votes)})
+	}
+
+	records = append(records, []string{}, []string{"user_id", "username", "option_id", "option", "rank", "voted_at"})
+	for _, vote := range e.Votes {
+		rank := ""
+		if vote.Rank > 0 {
+			rank = strconv.Itoa(vote.Rank)
+		}
+		records = append(records, []string{
+			formatUserID(vote.UserID), vote.Username,
+			strconv.FormatInt(vote.OptionID, 10), vote.Option,
+			rank, vote.VotedAt.Format(time.RFC3339),
+		})
+	}
+
+	if e.History != nil {
+		records = append(records, []string{}, []string{"user_id", "option_id", "option", "action", "clicked_at"})
+		for _, item := range e.History {
+			records = append(records, []string{
+				formatUserID(item.UserID),
+				strconv.FormatInt(item.OptionID, 10), item.Option,
+				item.Action, item.ClickedAt.Format(time.RFC3339),
+			})
+		}
+	}
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,1601 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wubrg-voting-bot/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gopkg.in/telebot.v4"
+)
+
+// apiCall запрос бота к Bot API, перехваченный fakeTelegram
+type apiCall struct {
+	Method string
+	Params map[string]any
+}
+
+// fakeTelegram минимальная подмена Bot API: запоминает запросы и отвечает успехом
+type fakeTelegram struct {
+	mu            sync.Mutex
+	calls         []apiCall
+	nextMessageID int
+	flood         map[string]int // метод -> retry_after для ближайшего ответа 429
+	server        *httptest.Server
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	t.Helper()
+	tg := &fakeTelegram{nextMessageID: 1000}
+	tg.server = httptest.NewServer(http.HandlerFunc(tg.serve))
+	t.Cleanup(tg.server.Close)
+	return tg
+}
+
+func (tg *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	params := make(map[string]any)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		// Отправка файлов: поля формы и содержимое файлов попадают в params как строки
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			for key, values := range r.MultipartForm.Value {
+				params[key] = values[0]
+			}
+			for key, files := range r.MultipartForm.File {
+				if f, err := files[0].Open(); err == nil {
+					content, _ := io.ReadAll(f)
+					f.Close()
+					params[key] = string(content)
+				}
+			}
+		}
+	} else {
+		_ = json.NewDecoder(r.Body).Decode(&params)
+	}
+
+	tg.mu.Lock()
+	tg.calls = append(tg.calls, apiCall{Method: method, Params: params})
+	tg.nextMessageID++
+	messageID := tg.nextMessageID
+	retryAfter, flooded := tg.flood[method]
+	delete(tg.flood, method)
+	tg.mu.Unlock()
+
+	if flooded {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after %d","parameters":{"retry_after":%d}}`, retryAfter, retryAfter)
+		return
+	}
+
+	// Отправка и редактирование сообщения в чате возвращают Message, остальное — true
+	result := "true"
+	if chatID, ok := params["chat_id"]; ok && (method == "sendMessage" || method == "sendDocument" || method == "editMessageText") {
+		result = fmt.Sprintf(`{"message_id":%d,"date":0,"chat":{"id":%v,"type":"private"}}`, messageID, chatID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+}
+
+// floodNext отвечает на ближайший запрос method ошибкой 429 с retry_after
+func (tg *fakeTelegram) floodNext(method string, retryAfter int) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	if tg.flood == nil {
+		tg.flood = make(map[string]int)
+	}
+	tg.flood[method] = retryAfter
+}
+
+// callsOf возвращает запросы с указанным методом
+func (tg *fakeTelegram) callsOf(method string) []apiCall {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	calls := make([]apiCall, 0)
+	for _, call := range tg.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// lastText возвращает параметр text последнего запроса с указанным методом
+func (tg *fakeTelegram) lastText(t *testing.T, method string) string {
+	t.Helper()
+	calls := tg.callsOf(method)
+	if len(calls) == 0 {
+		t.Fatalf("не было запросов %s", method)
+	}
+	text, _ := calls[len(calls)-1].Params["text"].(string)
+	return text
+}
+
+// testEnv бот поверх MemoryStore и подмены Bot API
+type testEnv struct {
+	t      *testing.T
+	bot    *Bot
+	store  *MemoryStore
+	tg     *fakeTelegram
+	update int
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	cfg := config.Default()
+	// Тесты обработчиков забирают очередь вручную, интервал перерисовки проверяется отдельно
+	cfg.RenderInterval = 0
+	return newTestEnvWith(t, cfg)
+}
+
+// newTestEnvWith создает окружение с настройками бота cfg
+func newTestEnvWith(t *testing.T, cfg config.Config) *testEnv {
+	t.Helper()
+	tg := newFakeTelegram(t)
+	api, err := telebot.NewBot(telebot.Settings{
+		Token:       "test",
+		URL:         tg.server.URL,
+		Offline:     true,
+		Synchronous: true,
+	})
+	if err != nil {
+		t.Fatalf("не удалось создать бота: %v", err)
+	}
+	api.Me.Username = "wubrg_test_bot"
+
+	store := NewMemoryStore()
+	b := newBot(api, store, NewMemorySessionStore(DefaultSessionTTL), cfg)
+	return &testEnv{
+		t:     t,
+		bot:   b,
+		store: store,
+		tg:    tg,
+	}
+}
+
+func testUser(id int64, username string) *telebot.User {
+	return &telebot.User{ID: id, Username: username, FirstName: strings.ToUpper(username[:1]) + username[1:]}
+}
+
+func (e *testEnv) process(u telebot.Update) {
+	e.update++
+	u.ID = e.update
+	e.bot.bot.ProcessUpdate(u)
+}
+
+// sendText отправляет боту текстовое сообщение (или команду) в личном чате
+func (e *testEnv) sendText(user *telebot.User, text string) {
+	e.process(telebot.Update{Message: &telebot.Message{
+		ID:     e.update + 1,
+		Sender: user,
+		Chat:   &telebot.Chat{ID: user.ID, Type: telebot.ChatPrivate},
+		Text:   text,
+	}})
+}
+
+// click нажимает inline-кнопку с данными data (в формате "\funique|payload")
+func (e *testEnv) click(user *telebot.User, data string) {
+	e.process(telebot.Update{Callback: &telebot.Callback{
+		ID:     fmt.Sprintf("cb%d", e.update+1),
+		Sender: user,
+		Data:   data,
+		Message: &telebot.Message{
+			ID:   500,
+			Chat: &telebot.Chat{ID: user.ID, Type: telebot.ChatPrivate},
+		},
+	}})
+}
+
+// lastAnswer возвращает текст последнего ответа на нажатие кнопки
+func (e *testEnv) lastAnswer() string {
+	return e.tg.lastText(e.t, "answerCallbackQuery")
+}
+
+// createPoll создает голосование напрямую в хранилище
+func (e *testEnv) createPoll(creator *telebot.User, draft PollDraft) *PollData {
+	e.t.Helper()
+	if draft.VoteType == "" {
+		draft.VoteType = VoteTypePlurality
+	}
+	pollID, err := e.store.CreatePoll(context.Background(), creator.ID, creator.Username, draft)
+	if err != nil {
+		e.t.Fatalf("CreatePoll: %v", err)
+	}
+	return e.poll(pollID)
+}
+
+// createRankedPoll создает рейтинговое голосование через мастер, как это делает пользователь
+func (e *testEnv) createRankedPoll(creator *telebot.User, title string, options ...string) *PollData {
+	e.t.Helper()
+	e.sendText(creator, "/createpoll")
+	e.sendText(creator, title)
+	e.click(creator, "\fpoll_description_skip")
+	for _, option := range options {
+		e.sendText(creator, option)
+	}
+	e.click(creator, "\fpoll_done")
+	e.click(creator, "\fpoll_mode_ranked")
+	e.click(creator, "\fpoll_deadline_skip")
+	e.click(creator, "\fpoll_confirm_yes")
+
+	polls, err := e.store.ListActivePolls(context.Background(), creator.ID, 10)
+	if err != nil || len(polls) == 0 {
+		e.t.Fatalf("мастер не создал голосование: %v (err=%v)", polls, err)
+	}
+	return e.poll(polls[0].ID)
+}
+
+// poll возвращает текущее состояние голосования из хранилища
+func (e *testEnv) poll(pollID int64) *PollData {
+	e.t.Helper()
+	poll, err := e.store.GetPoll(context.Background(), pollID)
+	if err != nil {
+		e.t.Fatalf("GetPoll(%d): %v", pollID, err)
+	}
+	return poll
+}
+
+func voteData(poll *PollData, option int) string {
+	return fmt.Sprintf("\fvote|%d|%d", poll.ID, poll.Options[option].ID)
+}
+
+// votesByOption возвращает число голосов за каждый вариант
+func votesByOption(poll *PollData) []int {
+	counts := make([]int, 0, len(poll.Options))
+	for _, opt := range poll.Options {
+		counts = append(counts, len(opt.Votes))
+	}
+	return counts
+}
+
+func TestCreatePollWizard(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+
+	e.sendText(alice, "/createpoll")
+	e.sendText(alice, "Лучший цвет")
+	e.sendText(alice, "Для следующего драфта")
+	e.sendText(alice, "Белый")
+	e.click(alice, "\fpoll_done")
+	if !strings.Contains(e.lastAnswer(), "минимум 2 варианта") {
+		t.Fatalf("с одним вариантом мастер не должен продолжаться, ответ: %q", e.lastAnswer())
+	}
+	e.sendText(alice, "Синий")
+	e.click(alice, "\fpoll_done")
+	e.click(alice, "\fpoll_mode_multi")
+	e.sendText(alice, "2")
+	e.click(alice, "\fpoll_anon_yes")
+	e.sendText(alice, "2h")
+	e.click(alice, "\fpoll_confirm_yes")
+
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "Голосование успешно создано") {
+		t.Fatalf("нет сообщения об успехе: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	polls, err := e.store.ListActivePolls(context.Background(), alice.ID, 10)
+	if err != nil || len(polls) != 1 {
+		t.Fatalf("ожидалось одно голосование, получено %v (err=%v)", polls, err)
+	}
+	poll := e.poll(polls[0].ID)
+	if poll.Title != "Лучший цвет" || len(poll.Options) != 2 || poll.Options[1].Text != "Синий" {
+		t.Errorf("неверные данные голосования: %+v", poll)
+	}
+	if poll.Description != "Для следующего драфта" || !strings.HasPrefix(formatPollMessage(poll), "Лучший цвет\nДля следующего драфта\n") {
+		t.Errorf("описание не сохранено или не показано под заголовком: %q", formatPollMessage(poll))
+	}
+	// Лимит равен числу вариантов, поэтому не сохраняется
+	if !poll.AllowMultiple || poll.MaxSelections != 0 || !poll.IsAnonymous || poll.ExpiresAt == nil {
+		t.Errorf("неверные настройки голосования: %+v", poll)
+	}
+	if state := e.bot.dialog.GetContext(alice.ID).State; state != StateIdle {
+		t.Errorf("после создания диалог должен быть завершен, состояние %q", state)
+	}
+}
+
+func TestWizardSurvivesRestart(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+
+	e.sendText(alice, "/createpoll")
+	e.sendText(alice, "Переживет перезапуск")
+	e.click(alice, "\fpoll_description_skip")
+
+	// Новый менеджер поверх того же хранилища сессий — как после перезапуска бота
+	e.bot.dialog = NewDialogManager(e.bot.dialog.store)
+	e.sendText(alice, "Да")
+
+	draft := e.bot.pollDraft(alice.ID)
+	if draft.Title != "Переживет перезапуск" || draft.Description != "" || len(draft.Options) != 1 {
+		t.Errorf("черновик потерян: %+v", draft)
+	}
+}
+
+func TestWizardUsesConfiguredLimits(t *testing.T) {
+	e := newTestEnv(t)
+	e.bot.limits.TitleMaxLength = 10
+	alice := testUser(1, "alice")
+
+	e.sendText(alice, "/createpoll")
+	e.sendText(alice, "Слишком длинный заголовок")
+	if got := e.tg.lastText(t, "sendMessage"); !strings.Contains(got, "максимум символов: 10") {
+		t.Errorf("ожидался отказ по настроенному лимиту: %q", got)
+	}
+
+	// Длина считается в символах, а не в байтах: 10 кириллических букв укладываются в лимит
+	e.sendText(alice, "Цвет маны!")
+	if draft := e.bot.pollDraft(alice.ID); draft.Title != "Цвет маны!" {
+		t.Errorf("заголовок в пределах лимита отклонен: %+v", draft)
+	}
+}
+
+func TestHandlersLogStructuredRecords(t *testing.T) {
+	e := newTestEnv(t)
+	var buf bytes.Buffer
+	e.bot.log = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	alice := testUser(1, "alice")
+
+	e.sendText(alice, "/help")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("запись не в формате JSON: %v (%q)", err, buf.String())
+	}
+	if record["handler"] != "/help" || record["user_id"] != float64(alice.ID) || record["chat_id"] != float64(alice.ID) {
+		t.Errorf("поля записи: %v", record)
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Errorf("нет длительности обработчика: %v", record)
+	}
+}
+
+func TestSingleChoiceVoteReplacesPrevious(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+
+	e.click(bob, voteData(poll, 0))
+	if e.lastAnswer() != "✅ Ваш голос учтен!" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+	e.click(bob, voteData(poll, 1))
+	if e.lastAnswer() != "🔄 Голос изменен" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+
+	got := e.poll(poll.ID)
+	if counts := votesByOption(got); counts[0] != 0 || counts[1] != 1 {
+		t.Errorf("голос должен перейти на второй вариант: %v", counts)
+	}
+	if got.TotalVoters != 1 {
+		t.Errorf("TotalVoters = %d, ожидался 1", got.TotalVoters)
+	}
+	if len(e.store.voteLog) != 2 {
+		t.Errorf("каждое нажатие пишется в vote_log: %d записей", len(e.store.voteLog))
+	}
+	if pending := e.bot.updateQueue.drain(); len(pending) != 1 || pending[0] != poll.ID {
+		t.Errorf("голосование должно быть в очереди обновлений: %v", pending)
+	}
+}
+
+func TestRepeatClickWithdrawsVote(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+
+	e.click(alice, voteData(poll, 0))
+	e.click(bob, voteData(poll, 0))
+	e.click(bob, voteData(poll, 0))
+	if e.lastAnswer() != "↩️ Голос отозван" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+
+	got := e.poll(poll.ID)
+	if counts := votesByOption(got); counts[0] != 1 || got.TotalVoters != 1 {
+		t.Errorf("должен остаться только голос alice: %v, voters=%d", counts, got.TotalVoters)
+	}
+
+	actions := make([]string, 0, len(e.store.voteLog))
+	for _, entry := range e.store.voteLog {
+		actions = append(actions, entry.Action)
+	}
+	want := []string{voteActionRecorded, voteActionRecorded, voteActionWithdrawn}
+	if !slices.Equal(actions, want) {
+		t.Errorf("vote_log.action = %v, ожидалось %v", actions, want)
+	}
+
+	// После отзыва можно проголосовать заново
+	e.click(bob, voteData(poll, 1))
+	if e.lastAnswer() != "✅ Ваш голос учтен!" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+}
+
+func TestVoteClicksAreRateLimited(t *testing.T) {
+	e := newTestEnv(t)
+	clock := time.Now()
+	e.bot.voteLimiter.now = func() time.Time { return clock }
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+
+	for i := range e.bot.rateLimit.VoteUserBurst + 1 {
+		e.click(bob, voteData(poll, i%2))
+	}
+	if !strings.HasPrefix(e.lastAnswer(), "🐢") {
+		t.Fatalf("нажатие сверх лимита должно быть отклонено: %q", e.lastAnswer())
+	}
+	// Отклоненное нажатие не меняет голос: последним учтен выбор на нажатии VoteUserBurst-1
+	if counts := votesByOption(e.poll(poll.ID)); counts[(e.bot.rateLimit.VoteUserBurst-1)%2] != 1 {
+		t.Errorf("голос bob изменился после отклоненного нажатия: %v", counts)
+	}
+	last := e.store.voteLog[len(e.store.voteLog)-1]
+	if last.Action != voteActionThrottled || last.UserID != bob.ID {
+		t.Errorf("отклоненное нажатие не записано в vote_log: %+v", last)
+	}
+
+	// Лимит одного пользователя не мешает другим
+	e.click(alice, voteData(poll, 0))
+	if strings.HasPrefix(e.lastAnswer(), "🐢") {
+		t.Errorf("alice не должна упираться в лимит bob: %q", e.lastAnswer())
+	}
+
+	// Через секунду корзина пополняется на одно нажатие
+	clock = clock.Add(time.Second)
+	e.click(bob, voteData(poll, 1))
+	if strings.HasPrefix(e.lastAnswer(), "🐢") {
+		t.Errorf("после паузы нажатие должно пройти: %q", e.lastAnswer())
+	}
+}
+
+func TestVoteLimiterPollBucket(t *testing.T) {
+	cfg := config.Default().RateLimit
+	l := NewVoteLimiter(cfg)
+	clock := time.Now()
+	l.now = func() time.Time { return clock }
+
+	// Разные пользователи расходуют общий лимит голосования
+	burst := int64(cfg.VotePollBurst)
+	for user := range burst {
+		if !l.Allow(user, 1) {
+			t.Fatalf("нажатие %d в пределах лимита голосования отклонено", user)
+		}
+	}
+	if l.Allow(burst, 1) {
+		t.Error("нажатие сверх лимита голосования должно быть отклонено")
+	}
+	if !l.Allow(burst, 2) {
+		t.Error("лимит одного голосования не должен влиять на другое")
+	}
+
+	// Давно неиспользуемые корзины удаляются
+	clock = clock.Add(voteLimiterIdleTTL)
+	l.Allow(0, 3)
+	if len(l.users) != 1 || len(l.polls) != 1 {
+		t.Errorf("старые корзины не удалены: users=%d polls=%d", len(l.users), len(l.polls))
+	}
+}
+
+func TestMultipleChoiceToggleAndLimit(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+	poll := e.createPoll(alice, PollDraft{
+		Title:         "Языки",
+		Options:       []string{"Go", "Rust", "Zig"},
+		AllowMultiple: true,
+		MaxSelections: 2,
+	})
+
+	e.click(alice, voteData(poll, 0))
+	e.click(alice, voteData(poll, 1))
+	if e.lastAnswer() != "✅ Вариант выбран" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+
+	e.click(alice, voteData(poll, 2))
+	if !strings.Contains(e.lastAnswer(), "не более 2 вариантов") {
+		t.Errorf("третий вариант должен упереться в лимит: %q", e.lastAnswer())
+	}
+
+	e.click(alice, voteData(poll, 0))
+	if e.lastAnswer() != "☑️ Выбор снят" {
+		t.Errorf("повторное нажатие должно снимать выбор: %q", e.lastAnswer())
+	}
+	e.click(alice, voteData(poll, 2))
+
+	if counts := votesByOption(e.poll(poll.ID)); counts[0] != 0 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("неверные голоса: %v", counts)
+	}
+}
+
+func TestVoteRejectedInClosedAndRankedPolls(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+	closed := e.createPoll(alice, PollDraft{Title: "Закрыто", Options: []string{"А", "Б"}})
+	ranked := e.createPoll(alice, PollDraft{Title: "Рейтинг", Options: []string{"А", "Б"}, VoteType: VoteTypeRanked})
+	if err := e.store.SetPollActive(context.Background(), closed.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	e.click(alice, voteData(closed, 0))
+	if e.lastAnswer() != "🔒 Голосование завершено" {
+		t.Errorf("неожиданный ответ для завершенного: %q", e.lastAnswer())
+	}
+	e.click(alice, voteData(ranked, 0))
+	if !strings.Contains(e.lastAnswer(), "ранжировать") {
+		t.Errorf("неожиданный ответ для рейтингового: %q", e.lastAnswer())
+	}
+
+	if len(e.store.voteLog) != 0 || e.poll(closed.ID).TotalVotes != 0 {
+		t.Errorf("отклоненные нажатия не должны сохраняться")
+	}
+}
+
+func TestVoteRejectsOptionOfAnotherPoll(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+	pollA := e.createPoll(alice, PollDraft{Title: "Первое", Options: []string{"А", "Б"}})
+	pollB := e.createPoll(alice, PollDraft{Title: "Второе", Options: []string{"В", "Г"}})
+	ranked := e.createPoll(alice, PollDraft{Title: "Рейтинг", Options: []string{"Д", "Е"}, VoteType: VoteTypeRanked})
+
+	// Подделанный callback: голосование A, вариант из B
+	e.click(alice, fmt.Sprintf("\fvote|%d|%d", pollA.ID, pollB.Options[0].ID))
+	if e.lastAnswer() != "❌ Вариант не найден" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+	if len(e.store.votes) != 0 || len(e.store.voteLog) != 0 {
+		t.Errorf("голос за чужой вариант не должен сохраняться: %+v", e.store.votes)
+	}
+
+	// Бюллетень с вариантом другого голосования тоже отклоняется
+	err := e.store.SaveBallot(context.Background(), ranked.ID, voterFromUser(alice), []int64{ranked.Options[0].ID, pollA.Options[0].ID})
+	if !errors.Is(err, errOptionNotFound) {
+		t.Errorf("ожидалась errOptionNotFound, получено %v", err)
+	}
+	if len(e.store.ballots) != 0 {
+		t.Errorf("бюллетень с чужим вариантом не должен сохраняться")
+	}
+}
+
+func TestPublishPollOwnerOnly(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Публикация", Options: []string{"А", "Б"}})
+
+	e.sendText(bob, fmt.Sprintf("/publishpoll %d", poll.ID))
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "только свои") {
+		t.Errorf("чужое голосование нельзя публиковать: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(alice, fmt.Sprintf("/publishpoll %d", poll.ID))
+	if !strings.HasPrefix(e.tg.lastText(t, "sendMessage"), "Публикация") {
+		t.Errorf("должно быть отправлено голосование: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	chats, _ := e.store.ListPollChats(context.Background(), poll.ID)
+	if len(chats) != 1 || chats[0].ChatID != alice.ID || chats[0].MessageID == 0 {
+		t.Errorf("публикация не сохранена: %+v", chats)
+	}
+}
+
+func TestMetricsCountVotesAndEdits(t *testing.T) {
+	e := newTestEnv(t)
+	reg := prometheus.NewRegistry()
+	if err := e.bot.RegisterMetrics(reg); err != nil {
+		t.Fatalf("RegisterMetrics: %v", err)
+	}
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Метрики", Options: []string{"А", "Б"}})
+	e.sendText(alice, fmt.Sprintf("/publishpoll %d", poll.ID))
+
+	e.click(bob, voteData(poll, 0))
+	e.click(bob, voteData(poll, 0))
+	if got := testutil.ToFloat64(e.bot.metrics.votes.WithLabelValues(voteActionRecorded)); got != 1 {
+		t.Errorf("votes_total{outcome=recorded} = %v", got)
+	}
+	if got := testutil.ToFloat64(e.bot.metrics.votes.WithLabelValues(voteActionWithdrawn)); got != 1 {
+		t.Errorf("votes_total{outcome=withdrawn} = %v", got)
+	}
+	if got, err := testutil.GatherAndCount(reg, "wubrg_update_queue_pending"); err != nil || got != 1 {
+		t.Errorf("нет метрики очереди: %d, %v", got, err)
+	}
+
+	// Первая перерисовка правит сообщение, вторая пропускается по хешу
+	e.bot.updatePollMessages(poll.ID)
+	e.bot.updatePollMessages(poll.ID)
+	if got := testutil.ToFloat64(e.bot.metrics.messageEdits.WithLabelValues(editResultUpdated)); got != 1 {
+		t.Errorf("poll_message_edits_total{result=updated} = %v", got)
+	}
+	if got := testutil.ToFloat64(e.bot.metrics.messageEdits.WithLabelValues(editResultSkipped)); got != 1 {
+		t.Errorf("poll_message_edits_total{result=skipped} = %v", got)
+	}
+	if got := testutil.CollectAndCount(e.bot.metrics.handlerDuration); got < 2 {
+		t.Errorf("гистограмма обработчиков: %d серий", got)
+	}
+
+	// Запрос на перерисовку после остановки виден как отброшенный
+	e.bot.updateQueue.close()
+	e.bot.updateQueue.Schedule(poll.ID)
+	if got, err := testutil.GatherAndCount(reg, "wubrg_update_queue_dropped_total"); err != nil || got != 1 {
+		t.Errorf("нет метрики отброшенных перерисовок: %d, %v", got, err)
+	}
+	if got := e.bot.updateQueue.Stats().Dropped; got != 1 {
+		t.Errorf("отброшено перерисовок: %d", got)
+	}
+}
+
+func TestClosePollRerendersPublishedMessages(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Итоги", Options: []string{"А", "Б"}})
+	e.sendText(alice, fmt.Sprintf("/publishpoll %d", poll.ID))
+	e.click(bob, voteData(poll, 1))
+
+	e.sendText(bob, fmt.Sprintf("/closepoll %d", poll.ID))
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "только своими") {
+		t.Errorf("чужое голосование нельзя завершить: %q", e.tg.lastText(t, "sendMessage"))
+	}
+	e.sendText(alice, fmt.Sprintf("/closepoll %d", poll.ID))
+	if !e.poll(poll.ID).IsClosed() {
+		t.Fatal("голосование должно быть завершено")
+	}
+
+	e.bot.updatePollMessages(poll.ID)
+	edits := e.tg.callsOf("editMessageText")
+	if len(edits) != 1 {
+		t.Fatalf("ожидалось одно редактирование, получено %d", len(edits))
+	}
+	text, _ := edits[0].Params["text"].(string)
+	if !strings.Contains(text, "🏆 Победитель: Б") {
+		t.Errorf("в итоговом сообщении нет победителя: %q", text)
+	}
+	if _, hasMarkup := edits[0].Params["reply_markup"]; hasMarkup {
+		t.Errorf("у завершенного голосования не должно быть кнопок")
+	}
+
+	// Хеш сохранен — повторная перерисовка без изменений пропускается
+	e.bot.updatePollMessages(poll.ID)
+	if len(e.tg.callsOf("editMessageText")) != 1 {
+		t.Errorf("неизмененное сообщение не должно редактироваться повторно")
+	}
+}
+
+func TestUpdateWorkerRetriesAfterFloodError(t *testing.T) {
+	e := newTestEnv(t)
+	clock := time.Now()
+	e.bot.editBudget.now = func() time.Time { return clock }
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Флуд", Options: []string{"А", "Б"}})
+	e.sendText(alice, fmt.Sprintf("/publishpoll %d", poll.ID))
+	e.click(bob, voteData(poll, 0))
+	e.bot.updateQueue.drain()
+
+	e.tg.floodNext("editMessageText", 7)
+	e.bot.updatePollMessages(poll.ID)
+	if len(e.tg.callsOf("editMessageText")) != 1 {
+		t.Fatalf("ожидалась одна попытка редактирования")
+	}
+	if _, delayed := e.bot.updateQueue.delayed[poll.ID]; !delayed {
+		t.Error("после 429 обновление должно быть отложено")
+	}
+
+	// До истечения retry_after чат не редактируется
+	e.bot.updatePollMessages(poll.ID)
+	if len(e.tg.callsOf("editMessageText")) != 1 {
+		t.Error("во время паузы от Telegram правок быть не должно")
+	}
+
+	clock = clock.Add(8 * time.Second)
+	e.bot.updatePollMessages(poll.ID)
+	if len(e.tg.callsOf("editMessageText")) != 2 {
+		t.Fatal("после паузы сообщение должно быть перерисовано")
+	}
+	e.bot.updatePollMessages(poll.ID)
+	if len(e.tg.callsOf("editMessageText")) != 2 {
+		t.Error("после успешной правки хеш должен быть сохранен")
+	}
+}
+
+func TestUpdateQueueCoalescesRendersWithinInterval(t *testing.T) {
+	q := NewUpdateQueue(time.Minute)
+	clock := time.Now()
+	q.now = func() time.Time { return clock }
+
+	q.Schedule(1)
+	q.Schedule(1)
+	if polls := q.drain(); !slices.Equal(polls, []int64{1}) {
+		t.Fatalf("первая перерисовка должна пройти сразу: %v", polls)
+	}
+
+	// Внутри интервала перерисовка откладывается до его конца, запросы сливаются
+	clock = clock.Add(10 * time.Second)
+	q.Schedule(1)
+	if polls := q.drain(); len(polls) != 0 {
+		t.Fatalf("перерисовка внутри интервала должна быть отложена: %v", polls)
+	}
+	q.Schedule(1)
+	q.Schedule(2)
+	if polls := q.drain(); !slices.Equal(polls, []int64{2}) {
+		t.Fatalf("интервал одного голосования не должен задерживать другое: %v", polls)
+	}
+	if at := q.delayed[1]; !at.Equal(clock.Add(50 * time.Second)) {
+		t.Errorf("отложенная перерисовка должна прийтись на конец интервала: %s", at.Sub(clock))
+	}
+
+	stats := q.Stats()
+	want := UpdateQueueStats{Pending: 0, Delayed: 1, Scheduled: 5, Coalesced: 2, Rendered: 2}
+	if stats != want {
+		t.Errorf("Stats() = %+v, ожидалось %+v", stats, want)
+	}
+
+	// После интервала голосование снова перерисовывается сразу
+	clock = clock.Add(time.Minute)
+	delete(q.delayed, 1)
+	q.Schedule(1)
+	if polls := q.drain(); !slices.Equal(polls, []int64{1}) {
+		t.Errorf("после интервала перерисовка должна пройти сразу: %v", polls)
+	}
+}
+
+// idlePoller поллер без обновлений: ждет остановки бота
+type idlePoller struct{}
+
+func (idlePoller) Poll(_ *telebot.Bot, _ chan telebot.Update, stop chan struct{}) {
+	<-stop
+}
+
+func TestStopRendersQueuedPolls(t *testing.T) {
+	cfg := config.Default()
+	cfg.RenderInterval = config.Duration(time.Minute)
+	e := newTestEnvWith(t, cfg)
+	e.bot.bot.Poller = idlePoller{}
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Остановка", Options: []string{"А", "Б"}})
+	e.sendText(alice, fmt.Sprintf("/publishpoll %d", poll.ID))
+
+	e.bot.Start()
+
+	e.click(bob, voteData(poll, 0))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(e.tg.callsOf("editMessageText")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("воркер не перерисовал голосование")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Вторая перерисовка внутри интервала откладывается, при остановке она выполняется сразу
+	e.click(alice, voteData(poll, 1))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.bot.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	edits := e.tg.callsOf("editMessageText")
+	if len(edits) != 2 {
+		t.Fatalf("ожидалось 2 перерисовки, получено %d", len(edits))
+	}
+	if text, _ := edits[1].Params["text"].(string); !strings.Contains(text, "2 people voted") {
+		t.Errorf("при остановке должно быть показано последнее состояние: %q", text)
+	}
+
+	// После остановки очередь новых задач не принимает, но учитывает отброшенные
+	e.bot.updateQueue.Schedule(poll.ID)
+	if stats := e.bot.updateQueue.Stats(); stats.Pending != 0 || stats.Delayed != 0 || stats.Dropped != 1 {
+		t.Errorf("очередь после остановки должна быть пустой и учесть отброшенный запрос: %+v", stats)
+	}
+}
+
+func TestStopRightAfterStart(t *testing.T) {
+	e := newTestEnv(t)
+	e.bot.bot.Poller = idlePoller{}
+
+	// Сигнал может прийти сразу после запуска: Stop должен остановить поллер, а не ждать вечно
+	e.bot.Start()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.bot.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestReadinessFollowsBotLifecycle(t *testing.T) {
+	e := newTestEnv(t)
+	e.bot.bot.Poller = idlePoller{}
+	migrationsCurrent := true
+	handler := NewHealthHandler(append(e.bot.ReadinessChecks(), ReadinessCheck{
+		Name: "migrations",
+		Check: func(context.Context) error {
+			if !migrationsCurrent {
+				return errors.New("не применено миграций: 1")
+			}
+			return nil
+		},
+	})...)
+
+	probe := func(path string) (int, map[string]string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body struct {
+			Checks map[string]string `json:"checks"`
+		}
+		if path == "/readyz" {
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("ответ %s не в формате JSON: %v", path, err)
+			}
+		}
+		return rec.Code, body.Checks
+	}
+
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d", code)
+	}
+	code, checks := probe("/readyz")
+	if code != http.StatusServiceUnavailable || checks["database"] != "ok" || checks["updates"] == "ok" || checks["update_worker"] == "ok" {
+		t.Errorf("до запуска бот не готов: %d %v", code, checks)
+	}
+
+	go e.bot.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if code, _ = probe("/readyz"); code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("бот не стал готов: %v", checks)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	migrationsCurrent = false
+	if code, checks = probe("/readyz"); code != http.StatusServiceUnavailable || checks["migrations"] == "ok" {
+		t.Errorf("непримененные миграции должны снимать готовность: %d %v", code, checks)
+	}
+	migrationsCurrent = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.bot.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if code, checks = probe("/readyz"); code != http.StatusServiceUnavailable || checks["updates"] == "ok" || checks["update_worker"] == "ok" {
+		t.Errorf("после остановки бот не готов: %d %v", code, checks)
+	}
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz после остановки = %d", code)
+	}
+}
+
+func TestWebhookAcceptsRecordedUpdates(t *testing.T) {
+	e := newTestEnv(t)
+	hook := newWebhookPoller(config.Webhook{Listen: "127.0.0.1:0", SecretToken: "s3cret"})
+	if err := hook.listen(); err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	e.bot.bot.Poller = hook
+	e.bot.webhook = hook
+
+	e.bot.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.listenAddr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("HTTP-сервер webhook не запустился")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	endpoint := "http://" + hook.listenAddr().String() + "/telegram"
+
+	post := func(secret, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set(webhookSecretHeader, secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s: %v", endpoint, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Обновление в том виде, в каком его присылает Telegram
+	update := `{"update_id":1,"message":{"message_id":10,"date":1700000000,
+		"from":{"id":5,"is_bot":false,"first_name":"Eve","username":"eve"},
+		"chat":{"id":5,"type":"private","first_name":"Eve"},"text":"/start",
+		"entities":[{"type":"bot_command","offset":0,"length":6}]}}`
+
+	if code := post("wrong", update); code != http.StatusUnauthorized {
+		t.Errorf("неверный секрет: код %d", code)
+	}
+	if code := post("s3cret", "{"); code != http.StatusBadRequest {
+		t.Errorf("некорректный JSON: код %d", code)
+	}
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		t.Fatalf("GET %s: %v", endpoint, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET должен отклоняться: код %d", resp.StatusCode)
+	}
+	if len(e.tg.callsOf("sendMessage")) != 0 {
+		t.Fatal("отклоненные запросы не должны обрабатываться")
+	}
+
+	if code := post("s3cret", update); code != http.StatusOK {
+		t.Fatalf("обновление не принято: код %d", code)
+	}
+	for len(e.tg.callsOf("sendMessage")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("бот не ответил на /start, полученный через webhook")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.bot.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if hook.listenAddr() != nil {
+		t.Error("HTTP-сервер webhook должен быть остановлен")
+	}
+}
+
+func TestWebhookListenFailsOnBusyPort(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer busy.Close()
+
+	hook := newWebhookPoller(config.Webhook{Listen: busy.Addr().String()})
+	if err := hook.listen(); err == nil {
+		hook.ln.Close()
+		t.Fatal("занятый порт должен быть ошибкой запуска")
+	}
+}
+
+func TestEditBudgetPerChatAndGlobal(t *testing.T) {
+	cfg := config.Default().RateLimit
+	budget := newEditBudget(cfg)
+	clock := time.Now()
+	budget.now = func() time.Time { return clock }
+	maxWait := time.Duration(cfg.EditMaxWait)
+
+	for range cfg.EditChatBurst {
+		if delay := budget.reserve("chat:1"); delay != 0 {
+			t.Fatalf("правка в пределах бюджета чата отложена на %s", delay)
+		}
+	}
+	if delay := budget.reserve("chat:1"); delay <= maxWait {
+		t.Errorf("сверх бюджета чата правка должна откладываться через очередь, задержка %s", delay)
+	}
+
+	// Общий бюджет расходуют все чаты
+	for i := range cfg.EditGlobalBurst - cfg.EditChatBurst {
+		if delay := budget.reserve(fmt.Sprintf("chat:%d", i+2)); delay != 0 {
+			t.Fatalf("правка %d в пределах общего бюджета отложена на %s", i, delay)
+		}
+	}
+	if delay := budget.reserve("chat:100"); delay == 0 || delay > maxWait {
+		t.Errorf("сверх общего бюджета ожидалась короткая задержка, получено %s", delay)
+	}
+}
+
+func TestEditBudgetFloodPausesAllChats(t *testing.T) {
+	cfg := config.Default().RateLimit
+	budget := newEditBudget(cfg)
+	clock := time.Now()
+	budget.now = func() time.Time { return clock }
+
+	budget.penalize("chat:1", 5*time.Second)
+	if delay := budget.reserve("chat:2"); delay != 5*time.Second {
+		t.Errorf("после 429 правки в других чатах тоже должны ждать retry_after, задержка %s", delay)
+	}
+
+	clock = clock.Add(5 * time.Second)
+	if delay := budget.reserve("chat:2"); delay == 0 || delay > time.Duration(cfg.EditMaxWait) {
+		t.Errorf("сразу после паузы общий бюджет пуст, ожидалась короткая задержка, получено %s", delay)
+	}
+	clock = clock.Add(time.Second)
+	if delay := budget.reserve("chat:2"); delay != 0 {
+		t.Errorf("после паузы общий бюджет должен пополняться, задержка %s", delay)
+	}
+}
+
+func TestInlineQuerySearchAndPaging(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	for i := 1; i <= 7; i++ {
+		e.createPoll(alice, PollDraft{Title: fmt.Sprintf("Голосование %d", i), Options: []string{"А", "Б"}})
+	}
+	lunch := e.createPoll(alice, PollDraft{Title: "Пятничный ОБЕД", Description: "Пицца или суши", Options: []string{"А", "Б"}})
+	e.createPoll(bob, PollDraft{Title: "Обед у Боба", Options: []string{"А", "Б"}})
+
+	query := func(text, offset string) (ids []string, nextOffset string) {
+		e.process(telebot.Update{Query: &telebot.Query{ID: "q", Sender: alice, Text: text, Offset: offset}})
+		calls := e.tg.callsOf("answerInlineQuery")
+		params := calls[len(calls)-1].Params
+		var results []struct {
+			ID          string `json:"id"`
+			Description string `json:"description"`
+		}
+		raw, _ := json.Marshal(params["results"])
+		_ = json.Unmarshal(raw, &results)
+		for _, r := range results {
+			ids = append(ids, r.ID)
+			if r.ID == fmt.Sprint(lunch.ID) && r.Description != lunch.Description {
+				t.Errorf("описание inline-результата: %q", r.Description)
+			}
+		}
+		nextOffset, _ = params["next_offset"].(string)
+		return ids, nextOffset
+	}
+
+	ids, next := query("", "")
+	if len(ids) != config.Default().Inline.PageSize || next != "5" {
+		t.Errorf("первая страница: %v, next_offset=%q", ids, next)
+	}
+	ids, next = query("", next)
+	if len(ids) != 3 || next != "" {
+		t.Errorf("вторая страница: %v, next_offset=%q", ids, next)
+	}
+
+	ids, _ = query("обед", "")
+	if len(ids) != 1 || ids[0] != fmt.Sprint(lunch.ID) {
+		t.Errorf("поиск должен найти только свое голосование без учета регистра: %v", ids)
+	}
+	if ids, _ = query("суши", ""); len(ids) != 1 || ids[0] != fmt.Sprint(lunch.ID) {
+		t.Errorf("поиск по описанию: %v", ids)
+	}
+}
+
+func TestChosenInlineResultIsIdempotent(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+	poll := e.createPoll(alice, PollDraft{Title: "Inline", Options: []string{"А", "Б"}})
+
+	chosen := telebot.Update{InlineResult: &telebot.InlineResult{
+		Sender:    alice,
+		ResultID:  fmt.Sprint(poll.ID),
+		MessageID: "inline-1",
+	}}
+	e.process(chosen)
+	e.process(chosen)
+
+	chats, _ := e.store.ListPollChats(context.Background(), poll.ID)
+	if len(chats) != 1 || chats[0].InlineMessageID != "inline-1" {
+		t.Fatalf("inline-публикация должна сохраниться один раз: %+v", chats)
+	}
+
+	e.click(alice, voteData(poll, 0))
+	e.bot.updatePollMessages(poll.ID)
+	edits := e.tg.callsOf("editMessageText")
+	if len(edits) != 1 || edits[0].Params["inline_message_id"] != "inline-1" {
+		t.Errorf("inline-сообщение должно быть перерисовано: %+v", edits)
+	}
+}
+
+func TestRankedBallotSubmission(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Рейтинг", Options: []string{"А", "Б", "В"}, VoteType: VoteTypeRanked})
+
+	e.sendText(bob, fmt.Sprintf("/start %s%d", rankStartPrefix, poll.ID))
+	e.click(bob, fmt.Sprintf("\frank_pick|%d", poll.Options[2].ID))
+	e.click(bob, fmt.Sprintf("\frank_pick|%d", poll.Options[2].ID))
+	if e.lastAnswer() != "Этот вариант уже в списке" {
+		t.Errorf("повторный выбор должен отклоняться: %q", e.lastAnswer())
+	}
+	e.click(bob, fmt.Sprintf("\frank_pick|%d", poll.Options[0].ID))
+	e.click(bob, "\frank_submit")
+	if e.lastAnswer() != "✅ Бюллетень сохранен!" {
+		t.Errorf("неожиданный ответ: %q", e.lastAnswer())
+	}
+
+	got := e.poll(poll.ID)
+	want := []int64{poll.Options[2].ID, poll.Options[0].ID}
+	if len(got.Ballots) != 1 || fmt.Sprint(got.Ballots[0]) != fmt.Sprint(want) {
+		t.Errorf("бюллетень %v, ожидался %v", got.Ballots, want)
+	}
+	if state := e.bot.dialog.GetContext(bob.ID).State; state != StateIdle {
+		t.Errorf("после отправки ранжирование должно завершиться, состояние %q", state)
+	}
+}
+
+func TestExpiredPollsAreClosed(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+	past := time.Now().Add(-time.Minute)
+	poll := e.createPoll(alice, PollDraft{Title: "Истекло", Options: []string{"А", "Б"}, ExpiresAt: &past})
+
+	e.bot.closeExpiredPolls()
+	if e.poll(poll.ID).IsActive {
+		t.Error("голосование с истекшим сроком должно быть закрыто")
+	}
+
+	// При возобновлении истекший срок сбрасывается
+	e.sendText(alice, fmt.Sprintf("/reopenpoll %d", poll.ID))
+	if got := e.poll(poll.ID); got.IsClosed() || got.ExpiresAt != nil {
+		t.Errorf("голосование должно открыться без срока: %+v", got)
+	}
+}
+
+func TestMemoryStoreDeletePollCascades(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	alice := testUser(1, "alice")
+	poll := e.createPoll(alice, PollDraft{Title: "Удаление", Options: []string{"А", "Б"}})
+	other := e.createPoll(alice, PollDraft{Title: "Остается", Options: []string{"А", "Б"}})
+
+	e.click(alice, voteData(poll, 0))
+	e.click(alice, voteData(other, 0))
+	_ = e.store.AddPollChat(ctx, poll.ID, 10, 20)
+	_ = e.store.AddPollChat(ctx, poll.ID, 10, 20) // ON CONFLICT DO NOTHING
+
+	if chats, _ := e.store.ListPollChats(ctx, poll.ID); len(chats) != 1 {
+		t.Errorf("повторная публикация не должна дублироваться: %+v", chats)
+	}
+
+	if err := e.store.DeletePoll(ctx, poll.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.store.GetPoll(ctx, poll.ID); err != errPollNotFound {
+		t.Errorf("GetPoll после удаления: %v", err)
+	}
+	if chats, _ := e.store.ListPollChats(ctx, poll.ID); len(chats) != 0 {
+		t.Errorf("публикации должны удалиться каскадом: %+v", chats)
+	}
+	if len(e.store.votes) != 1 || e.poll(other.ID).TotalVotes != 1 {
+		t.Errorf("голоса другого голосования не должны пострадать")
+	}
+	if len(e.store.voteLog) != 2 {
+		t.Errorf("vote_log не удаляется вместе с голосованием: %d записей", len(e.store.voteLog))
+	}
+}
+
+func TestExportOwnerOnlyCSVAndJSON(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob, carol := testUser(1, "alice"), testUser(2, "bob"), testUser(3, "carol")
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+
+	e.click(bob, voteData(poll, 0))
+	e.click(carol, voteData(poll, 0))
+	e.click(carol, voteData(poll, 1))
+
+	e.sendText(bob, fmt.Sprintf("/export %d", poll.ID))
+	if len(e.tg.callsOf("sendDocument")) != 0 || !strings.Contains(e.tg.lastText(t, "sendMessage"), "только свои") {
+		t.Fatalf("чужое голосование выгружать нельзя: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(alice, fmt.Sprintf("/export %d csv history", poll.ID))
+	docs := e.tg.callsOf("sendDocument")
+	if len(docs) != 1 {
+		t.Fatalf("ожидался один документ, отправлено %d", len(docs))
+	}
+	content, _ := docs[0].Params["document"].(string)
+	for _, want := range []string{
+		"option_id,option,votes",
+		fmt.Sprintf("%d,Пицца,1", poll.Options[0].ID),
+		fmt.Sprintf("2,bob,%d,Пицца,,", poll.Options[0].ID),
+		fmt.Sprintf("3,carol,%d,Суши,,", poll.Options[1].ID),
+		"user_id,option_id,option,action,clicked_at",
+		fmt.Sprintf("3,%d,Суши,changed,", poll.Options[1].ID),
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("в CSV нет %q:\n%s", want, content)
+		}
+	}
+
+	e.sendText(alice, fmt.Sprintf("/export %d json", poll.ID))
+	docs = e.tg.callsOf("sendDocument")
+	var export pollExport
+	if err := json.Unmarshal([]byte(docs[len(docs)-1].Params["document"].(string)), &export); err != nil {
+		t.Fatalf("некорректный JSON: %v", err)
+	}
+	if len(export.Votes) != 2 || export.Tallies[1].Votes != 1 || export.History != nil {
+		t.Errorf("неожиданная выгрузка: %+v", export)
+	}
+}
+
+func TestExportHidesVotersOfAnonymousPoll(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Тайно", Options: []string{"Да", "Нет"}, IsAnonymous: true})
+	e.click(bob, voteData(poll, 1))
+
+	e.sendText(alice, fmt.Sprintf("/export %d json history", poll.ID))
+	docs := e.tg.callsOf("sendDocument")
+	if len(docs) != 1 {
+		t.Fatalf("ожидался один документ, отправлено %d", len(docs))
+	}
+	content := docs[0].Params["document"].(string)
+	if strings.Contains(content, "bob") || strings.Contains(content, `"user_id"`) {
+		t.Errorf("в выгрузке анонимного голосования есть данные участника:\n%s", content)
+	}
+}
+
+func TestExportHidesVotersOfRankedPoll(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createRankedPoll(alice, "Рейтинг", "А", "Б")
+	if !poll.IsAnonymous {
+		t.Fatalf("рейтинговое голосование должно создаваться анонимным: %+v", poll)
+	}
+	e.sendText(bob, fmt.Sprintf("/start %s%d", rankStartPrefix, poll.ID))
+	e.click(bob, fmt.Sprintf("\frank_pick|%d", poll.Options[1].ID))
+	e.click(bob, "\frank_submit")
+
+	e.sendText(alice, fmt.Sprintf("/export %d json history", poll.ID))
+	docs := e.tg.callsOf("sendDocument")
+	if len(docs) != 1 {
+		t.Fatalf("ожидался один документ, отправлено %d", len(docs))
+	}
+	content := docs[0].Params["document"].(string)
+	if strings.Contains(content, "bob") || strings.Contains(content, `"user_id"`) {
+		t.Errorf("в выгрузке рейтингового голосования есть данные участника:\n%s", content)
+	}
+}
+
+func TestOptionEmojiPrefix(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"🔴 Красный", "🔴"},
+		{"⚪ Белый (White)", "⚪"},
+		{"🟢  Зеленый", "🟢"},
+		{"❤️ Сердце", "❤️"},
+		{"1️⃣ Первый", "1️⃣"},
+		{"👍🏽 Да", "👍🏽"},
+		{"👨‍👩‍👧 Семья", "👨‍👩‍👧"},
+		{"Красный", ""},
+		{"🔴", ""},
+		{"🔴Красный", ""},
+		{"1 Первый", ""},
+		{"- пункт", ""},
+	}
+	for _, tt := range tests {
+		if got := optionEmoji(tt.text); got != tt.want {
+			t.Errorf("optionEmoji(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestOptionEmojiIsStoredAndRendered(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Цвет", Options: []string{"🔴 Красный", "Синий"}})
+
+	if poll.Options[0].Emoji != "🔴" || poll.Options[1].Emoji != "👍" {
+		t.Fatalf("эмодзи вариантов: %q, %q", poll.Options[0].Emoji, poll.Options[1].Emoji)
+	}
+
+	e.click(bob, voteData(poll, 0))
+	if msg := formatPollMessage(e.poll(poll.ID)); !strings.Contains(msg, "🔴 Красный – 1\n"+strings.Repeat("🔴", 14)+" 100%") {
+		t.Errorf("полоса результатов должна использовать эмодзи варианта:\n%s", msg)
+	}
+}
+
+func TestEditPollDialog(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Обдед", Options: []string{"Пица", "Суши", "Паста"}})
+	e.sendText(alice, fmt.Sprintf("/publishpoll %d", poll.ID))
+	e.click(bob, voteData(poll, 1))
+	e.bot.updatePollMessages(poll.ID)
+	e.bot.updateQueue.drain()
+
+	e.sendText(bob, fmt.Sprintf("/editpoll %d", poll.ID))
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "только свои") {
+		t.Fatalf("чужое голосование изменять нельзя: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(alice, fmt.Sprintf("/editpoll %d", poll.ID))
+	e.click(alice, "\fedit_title")
+	e.sendText(alice, "Обед")
+	e.click(alice, "\fedit_desc")
+	e.sendText(alice, "В пятницу")
+	e.click(alice, fmt.Sprintf("\fedit_rename|%d", poll.Options[0].ID))
+	e.sendText(alice, "🍕 Пицца")
+	e.click(alice, "\fedit_add")
+	e.sendText(alice, "Рамен")
+
+	e.click(alice, fmt.Sprintf("\fedit_remove|%d", poll.Options[1].ID))
+	if !strings.Contains(e.lastAnswer(), "уже голосовали") {
+		t.Errorf("вариант с голосами удалять нельзя: %q", e.lastAnswer())
+	}
+	e.click(alice, fmt.Sprintf("\fedit_remove|%d", poll.Options[2].ID))
+	e.click(alice, "\fedit_done")
+
+	got := e.poll(poll.ID)
+	texts := make([]string, 0, len(got.Options))
+	for _, opt := range got.Options {
+		texts = append(texts, opt.Text)
+	}
+	if got.Title != "Обед" || got.Description != "В пятницу" || !slices.Equal(texts, []string{"🍕 Пицца", "Суши", "Рамен"}) {
+		t.Errorf("неверное голосование после редактирования: %q / %q / %v", got.Title, got.Description, texts)
+	}
+	if got.Options[0].Emoji != "🍕" || len(got.Options[1].Votes) != 1 {
+		t.Errorf("эмодзи или голоса потеряны: %+v", got.Options)
+	}
+	if state := e.bot.dialog.GetContext(alice.ID).State; state != StateIdle {
+		t.Errorf("после «Готово» диалог должен быть завершен, состояние %q", state)
+	}
+
+	if pending := e.bot.updateQueue.drain(); len(pending) != 1 || pending[0] != poll.ID {
+		t.Fatalf("опубликованные копии должны быть поставлены в очередь: %v", pending)
+	}
+	e.bot.updatePollMessages(poll.ID)
+	edits := e.tg.callsOf("editMessageText")
+	text, _ := edits[len(edits)-1].Params["text"].(string)
+	if !strings.HasPrefix(text, "Обед\nВ пятницу") || !strings.Contains(text, "Рамен") {
+		t.Errorf("публикация не перерисована: %q", text)
+	}
+}
+
+func TestEditPollKeepsTwoOptions(t *testing.T) {
+	e := newTestEnv(t)
+	alice := testUser(1, "alice")
+	poll := e.createPoll(alice, PollDraft{Title: "Да или нет", Options: []string{"Да", "Нет"}})
+
+	e.sendText(alice, fmt.Sprintf("/editpoll %d", poll.ID))
+	e.click(alice, fmt.Sprintf("\fedit_remove|%d", poll.Options[0].ID))
+	if !strings.Contains(e.lastAnswer(), "минимум 2 варианта") {
+		t.Errorf("последние два варианта удалять нельзя: %q", e.lastAnswer())
+	}
+	if len(e.poll(poll.ID).Options) != 2 {
+		t.Errorf("вариант не должен быть удален")
+	}
+}
+
+func TestPollMessageHashIncludesKeyboard(t *testing.T) {
+	poll := &PollData{ID: 1, Title: "Т", IsActive: true, VoteType: VoteTypePlurality,
+		Options: []PollOption{{ID: 1, Text: "А"}, {ID: 2, Text: "Б"}}}
+	before := pollMessageHash("текст", pollMarkup(poll))
+
+	poll.Options[1].Text = "В"
+	if pollMessageHash("текст", pollMarkup(poll)) == before {
+		t.Error("изменение кнопок при том же тексте должно менять хеш")
+	}
+	if pollMessageHash("текст", nil) == before {
+		t.Error("удаление клавиатуры должно менять хеш")
+	}
+}
+
+func TestHistoryTimelineAndPaging(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+
+	e.click(bob, voteData(poll, 0))
+	e.click(bob, voteData(poll, 1))
+	for i := 0; i < e.bot.limits.HistoryPageSize; i++ {
+		e.click(alice, voteData(poll, i%2))
+	}
+
+	e.sendText(bob, fmt.Sprintf("/history %d", poll.ID))
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "только своих") {
+		t.Fatalf("чужую историю смотреть нельзя: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(alice, fmt.Sprintf("/history %d", poll.ID))
+	calls := e.tg.callsOf("sendMessage")
+	first := calls[len(calls)-1]
+	text, _ := first.Params["text"].(string)
+	for _, want := range []string{"Страница 1 из 2", "@bob ✅ проголосовал(а) за Пицца", "@bob 🔄 сменил(а) голос на Суши", "Нажатий: 17, участников: 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("в истории нет %q:\n%s", want, text)
+		}
+	}
+	if _, ok := first.Params["reply_markup"]; !ok {
+		t.Fatal("у многостраничной истории должны быть кнопки листания")
+	}
+
+	e.click(alice, fmt.Sprintf("\fhistory|%d|1", poll.ID))
+	page2 := e.tg.lastText(t, "editMessageText")
+	if !strings.Contains(page2, "Страница 2 из 2") || strings.Count(page2, "@alice") != 2 {
+		t.Errorf("вторая страница должна содержать оставшиеся 2 нажатия:\n%s", page2)
+	}
+}
+
+func TestHistoryHidesVotersOfAnonymousPoll(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob, carol := testUser(1, "alice"), testUser(2, "bob"), testUser(3, "carol")
+	poll := e.createPoll(alice, PollDraft{Title: "Тайно", Options: []string{"Да", "Нет"}, IsAnonymous: true})
+	e.click(bob, voteData(poll, 0))
+	e.click(carol, voteData(poll, 1))
+	e.click(bob, voteData(poll, 0))
+
+	e.sendText(alice, fmt.Sprintf("/history %d", poll.ID))
+	text := e.tg.lastText(t, "sendMessage")
+	if strings.Contains(text, "bob") || strings.Contains(text, "ID 2") {
+		t.Errorf("в истории анонимного голосования видны участники:\n%s", text)
+	}
+	if !strings.Contains(text, "Участник 1 ↩️ отозвал(а) голос за Да") || !strings.Contains(text, "Участник 2 ✅") {
+		t.Errorf("участники должны нумероваться по первому нажатию:\n%s", text)
+	}
+}
+
+func TestHistoryHidesVotersOfRankedPoll(t *testing.T) {
+	e := newTestEnv(t)
+	alice, bob := testUser(1, "alice"), testUser(2, "bob")
+	poll := e.createRankedPoll(alice, "Рейтинг", "А", "Б")
+	e.sendText(bob, fmt.Sprintf("/start %s%d", rankStartPrefix, poll.ID))
+	e.click(bob, fmt.Sprintf("\frank_pick|%d", poll.Options[0].ID))
+	e.click(bob, "\frank_submit")
+
+	e.sendText(alice, fmt.Sprintf("/history %d", poll.ID))
+	text := e.tg.lastText(t, "sendMessage")
+	if strings.Contains(text, "bob") || strings.Contains(text, "ID 2") {
+		t.Errorf("в истории рейтингового голосования видны участники:\n%s", text)
+	}
+	if !strings.Contains(text, "Участник 1") {
+		t.Errorf("бюллетень должен попасть в историю без имени:\n%s", text)
+	}
+}
+
+func TestAdminCommandsActOnAnyPoll(t *testing.T) {
+	e := newTestEnv(t)
+	ctx := context.Background()
+	admin, alice, bob := testUser(100, "admin"), testUser(1, "alice"), testUser(2, "bob")
+	e.bot.admins[admin.ID] = struct{}{}
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+	_ = e.store.AddPollChat(ctx, poll.ID, -1001, 42)
+	_ = e.store.AddInlinePollChat(ctx, poll.ID, "inline-1")
+	e.click(bob, voteData(poll, 0))
+
+	e.sendText(alice, "/admin stats")
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "только администраторам") {
+		t.Fatalf("не администратору команда недоступна: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(admin, "/admin stats")
+	stats := e.tg.lastText(t, "sendMessage")
+	for _, want := range []string{"Голосований: 1 (открыто: 1)", "Голосов: 1", "Публикаций: 2"} {
+		if !strings.Contains(stats, want) {
+			t.Errorf("в статистике нет %q: %q", want, stats)
+		}
+	}
+
+	e.sendText(admin, fmt.Sprintf("/admin whois %d", poll.ID))
+	whois := e.tg.lastText(t, "sendMessage")
+	for _, want := range []string{"@alice (ID 1)", "Публикации (2)", "чат -1001, сообщение 42", "inline-сообщение"} {
+		if !strings.Contains(whois, want) {
+			t.Errorf("в whois нет %q: %q", want, whois)
+		}
+	}
+
+	e.sendText(admin, fmt.Sprintf("/admin closepoll %d", poll.ID))
+	if !e.poll(poll.ID).IsClosed() {
+		t.Fatal("администратор должен завершать чужие голосования")
+	}
+
+	e.bot.updateQueue.drain()
+
+	e.sendText(admin, fmt.Sprintf("/admin deletepoll %d", poll.ID))
+	if _, err := e.store.GetPoll(ctx, poll.ID); !errors.Is(err, errPollNotFound) {
+		t.Fatalf("голосование не удалено: %v", err)
+	}
+	if pending := e.bot.updateQueue.drain(); !slices.Equal(pending, []int64{poll.ID}) {
+		t.Fatalf("сообщения удаленного голосования заменяет воркер очереди: %v", pending)
+	}
+
+	// Ответ 429 на одну из правок не теряет пометку: публикация ждет повтора через очередь
+	clock := time.Now()
+	e.bot.editBudget.now = func() time.Time { return clock }
+	e.tg.floodNext("editMessageText", 2)
+	e.bot.updatePollMessages(poll.ID)
+	if _, delayed := e.bot.updateQueue.delayed[poll.ID]; !delayed {
+		t.Fatal("после 429 замена должна быть отложена")
+	}
+	clock = clock.Add(3 * time.Second)
+	e.bot.updatePollMessages(poll.ID)
+
+	replaced := 0
+	for _, call := range e.tg.callsOf("editMessageText") {
+		if call.Params["text"] != deletedPollMessage {
+			t.Errorf("неожиданная правка: %+v", call.Params)
+		}
+		if _, hasMarkup := call.Params["reply_markup"]; hasMarkup {
+			t.Errorf("у удаленного голосования не должно быть кнопок: %+v", call.Params)
+		}
+		replaced++
+	}
+	if replaced != 3 || len(e.bot.deleted) != 0 {
+		t.Errorf("обе публикации должны быть заменены после повтора: правок %d, ожидают %d", replaced, len(e.bot.deleted))
+	}
+}
+
+func TestAdminBanBlocksCreatingAndVoting(t *testing.T) {
+	e := newTestEnv(t)
+	admin, alice, bob := testUser(100, "admin"), testUser(1, "alice"), testUser(2, "bob")
+	e.bot.admins[admin.ID] = struct{}{}
+	poll := e.createPoll(alice, PollDraft{Title: "Обед", Options: []string{"Пицца", "Суши"}})
+	e.click(bob, voteData(poll, 0))
+
+	e.sendText(admin, "/admin ban @Bob")
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "Пользователь 2 заблокирован") {
+		t.Fatalf("username должен находиться среди участников: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.click(bob, voteData(poll, 1))
+	if !strings.Contains(e.lastAnswer(), "заблокированы") || votesByOption(e.poll(poll.ID))[1] != 0 {
+		t.Errorf("заблокированный пользователь не может голосовать: %q", e.lastAnswer())
+	}
+	e.sendText(bob, "/createpoll")
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "заблокированы") {
+		t.Errorf("заблокированный пользователь не может создавать голосования: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(admin, "/admin ban 100")
+	if !strings.Contains(e.tg.lastText(t, "sendMessage"), "Нельзя заблокировать администратора") {
+		t.Errorf("администратора блокировать нельзя: %q", e.tg.lastText(t, "sendMessage"))
+	}
+
+	e.sendText(admin, "/admin unban 2")
+	e.click(bob, voteData(poll, 1))
+	if votesByOption(e.poll(poll.ID))[1] != 1 {
+		t.Errorf("после снятия блокировки голос должен учитываться: %q", e.lastAnswer())
+	}
+
+	// Блокировки из хранилища загружаются при запуске бота
+	carol := testUser(3, "carol")
+	if err := e.store.BanUser(context.Background(), carol.ID, admin.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.bot.loadBannedUsers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	e.click(carol, voteData(poll, 0))
+	if !strings.Contains(e.lastAnswer(), "заблокированы") {
+		t.Errorf("блокировка из хранилища должна действовать после запуска: %q", e.lastAnswer())
+	}
+}
+
+func TestBanListRefreshesFromStore(t *testing.T) {
+	cfg := config.Default()
+	cfg.RenderInterval = 0
+	cfg.BanRefreshInterval = config.Duration(10 * time.Millisecond)
+	e := newTestEnvWith(t, cfg)
+	e.bot.startBanRefresh()
+	t.Cleanup(func() { close(e.bot.stop) })
+
+	// Блокировки и разблокировки с другого экземпляра бота видны только в хранилище
+	ctx := context.Background()
+	waitBanned := func(userID int64, want bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for e.bot.isBanned(userID) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("isBanned(%d) = %v, ожидалось %v", userID, !want, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	if err := e.store.BanUser(ctx, 2, 100); err != nil {
+		t.Fatal(err)
+	}
+	waitBanned(2, true)
+	if err := e.store.UnbanUser(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	waitBanned(2, false)
+}
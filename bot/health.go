@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout сколько /readyz ждет все проверки вместе
+const readinessTimeout = 3 * time.Second
+
+// ReadinessCheck именованная проверка готовности принимать обновления
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// ReadinessChecks возвращает проверки бота: база данных доступна, обновления
+// от Telegram принимаются (long polling или webhook) и воркер перерисовки работает
+func (b *Bot) ReadinessChecks() []ReadinessCheck {
+	return []ReadinessCheck{
+		{Name: "database", Check: b.store.Ping},
+		{Name: "updates", Check: b.checkReceivingUpdates},
+		{Name: "update_worker", Check: b.checkUpdateWorker},
+	}
+}
+
+// checkReceivingUpdates проверяет, что поллер telebot запущен, а в режиме webhook — что поднят HTTP-сервер
+func (b *Bot) checkReceivingUpdates(context.Context) error {
+	if !b.polling.Load() {
+		return errors.New("прием обновлений не запущен")
+	}
+	if b.webhook != nil && b.webhook.listenAddr() == nil {
+		return errors.New("HTTP-сервер webhook не запущен")
+	}
+	return nil
+}
+
+// checkUpdateWorker проверяет, что воркер очереди обновлений работает
+func (b *Bot) checkUpdateWorker(context.Context) error {
+	if !b.workerRunning.Load() {
+		return errors.New("воркер обновления сообщений не работает")
+	}
+	return nil
+}
+
+// NewHealthHandler возвращает обработчик /healthz и /readyz.
+// /healthz отвечает 200, пока процесс обслуживает HTTP-запросы.
+// /readyz выполняет checks и отвечает 200, если все прошли, иначе 503;
+// в теле — JSON с результатом каждой проверки.
+func NewHealthHandler(checks ...ReadinessCheck) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		ready := true
+		results := make(map[string]string, len(checks))
+		for _, c := range checks {
+			if err := c.Check(ctx); err != nil {
+				ready = false
+				results[c.Name] = err.Error()
+				continue
+			}
+			results[c.Name] = "ok"
+		}
+
+		status := "ok"
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			status = "unavailable"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": results})
+	})
+	return mux
+}
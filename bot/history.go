@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v4"
+)
+
+// historyTimeLayout формат времени нажатия в /history
+const historyTimeLayout = "02.01 15:04:05"
+
+// historyActionLabels подписи итогов нажатия из vote_log.action
+var historyActionLabels = map[string]string{
+	voteActionRecorded:     "✅ проголосовал(а) за",
+	voteActionChanged:      "🔄 сменил(а) голос на",
+	voteActionWithdrawn:    "↩️ отозвал(а) голос за",
+	voteActionSelected:     "☑️ выбрал(а)",
+	voteActionDeselected:   "✖️ снял(а) выбор",
+	voteActionLimitReached: "⚠️ превысил(а) лимит, нажав",
+	voteActionBallot:       "🔢 отправил(а) бюллетень, 1-й выбор:",
+	voteActionThrottled:    "🐢 слишком часто нажимал(а), отклонено:",
+}
+
+// handleHistory показывает владельцу хронологию нажатий в голосовании
+func (b *Bot) handleHistory(c telebot.Context) error {
+	pollID, ok, err := parsePollIDArg(c.Text())
+	if !ok {
+		return c.Send("❌ Укажите ID голосования.\n\nИспользование: /history <ID>\n\nПосмотрите список голосований: /listpolls")
+	}
+	if err != nil {
+		return c.Send("❌ Некорректный ID голосования")
+	}
+
+	// История показывает, кто и когда менял выбор, поэтому не публикуется в группах
+	if c.Chat().Type != telebot.ChatPrivate {
+		return c.Send("❌ История доступна только в личном чате с ботом.")
+	}
+
+	text, markup, err := b.historyPage(c.Sender().ID, pollID, 0)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	return c.Send(text, markup)
+}
+
+// handleHistoryPageCallback переключает страницу истории
+func (b *Bot) handleHistoryPageCallback(c telebot.Context) error {
+	// формат: "\fhistory|pollID|page"
+	parts := strings.Split(strings.TrimPrefix(c.Data(), "\fhistory|"), "|")
+	if len(parts) != 2 {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка данных"})
+	}
+	pollID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка данных"})
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка данных"})
+	}
+
+	text, markup, err := b.historyPage(c.Sender().ID, pollID, page)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: err.Error()})
+	}
+	c.Respond(&telebot.CallbackResponse{})
+	return c.Edit(text, markup)
+}
+
+// historyPage загружает голосование и vote_log и форматирует страницу истории.
+// Ошибка содержит текст для пользователя.
+func (b *Bot) historyPage(userID, pollID int64, page int) (string, *telebot.ReplyMarkup, error) {
+	ctx := context.Background()
+
+	poll, err := b.store.GetPoll(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return "", nil, errors.New("❌ Голосование не найдено")
+	}
+	if poll.CreatorID != userID {
+		b.log.Warn("history of foreign poll denied", "user_id", userID, "poll_id", pollID, "owner_id", poll.CreatorID)
+		return "", nil, errors.New("❌ Вы можете смотреть историю только своих голосований.")
+	}
+
+	entries, err := b.store.ListVoteLog(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to get vote history", "poll_id", pollID, "error", err)
+		return "", nil, errors.New("❌ Ошибка получения истории")
+	}
+
+	// Имена берутся из текущих голосов: в vote_log хранится только Telegram ID
+	names := make(map[int64]string)
+	if !poll.IsAnonymous {
+		records, err := b.store.ListVoteRecords(ctx, pollID)
+		if err != nil {
+			b.log.Error("failed to get poll votes", "poll_id", pollID, "error", err)
+		}
+		for _, record := range records {
+			names[record.Voter.UserID] = voterName(record.Voter)
+		}
+	}
+
+	text, markup := formatHistoryPage(poll, entries, names, page, b.limits.HistoryPageSize)
+	return text, markup, nil
+}
+
+// voterName возвращает @username или имя участника
+func voterName(voter Vote) string {
+	if voter.Username != "" {
+		return "@" + voter.Username
+	}
+	return voter.FirstName
+}
+
+// formatHistoryPage форматирует страницу хронологии нажатий (по pageSize записей) и кнопки листания.
+// В анонимном голосовании участники нумеруются в порядке первого нажатия, без имен и ID.
+func formatHistoryPage(poll *PollData, entries []VoteLogEntry, names map[int64]string, page, pageSize int) (string, *telebot.ReplyMarkup) {
+	participants := make(map[int64]string)
+	changes := 0
+	for _, entry := range entries {
+		if _, seen := participants[entry.UserID]; !seen {
+			switch name := names[entry.UserID]; {
+			case poll.IsAnonymous:
+				participants[entry.UserID] = fmt.Sprintf("Участник %d", len(participants)+1)
+			case name != "":
+				participants[entry.UserID] = name
+			default:
+				participants[entry.UserID] = fmt.Sprintf("ID %d", entry.UserID)
+			}
+		}
+		if entry.Action == voteActionChanged || entry.Action == voteActionWithdrawn || entry.Action == voteActionDeselected {
+			changes++
+		}
+	}
+
+	text := fmt.Sprintf("📜 История голосования %d: %s\n", poll.ID, poll.Title)
+	if poll.IsAnonymous {
+		text += "🕶 Анонимное голосование: участники показаны под номерами\n"
+	}
+	text += fmt.Sprintf("Нажатий: %d, участников: %d, смен и отзывов: %d\n", len(entries), len(participants), changes)
+
+	if len(entries) == 0 {
+		return text + "\nНажатий пока не было.", nil
+	}
+
+	pages := (len(entries) + pageSize - 1) / pageSize
+	page = max(0, min(page, pages-1))
+	start := page * pageSize
+	end := min(start+pageSize, len(entries))
+
+	text += fmt.Sprintf("\nСтраница %d из %d:\n", page+1, pages)
+	for _, entry := range entries[start:end] {
+		label, ok := historyActionLabels[entry.Action]
+		if !ok {
+			label = "👆 нажал(а)"
+		}
+		text += fmt.Sprintf("%s %s %s %s\n",
+			entry.ClickedAt.Local().Format(historyTimeLayout),
+			participants[entry.UserID], label, pollOptionText(poll, entry.OptionID))
+	}
+
+	if pages == 1 {
+		return text, nil
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	buttons := make([]telebot.Btn, 0, 2)
+	pollIDArg := strconv.FormatInt(poll.ID, 10)
+	if page > 0 {
+		buttons = append(buttons, markup.Data("⬅️ Назад", "history", pollIDArg, strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		buttons = append(buttons, markup.Data("Вперед ➡️", "history", pollIDArg, strconv.Itoa(page+1)))
+	}
+	markup.Inline(markup.Row(buttons...))
+	return text, markup
+}
@@ -0,0 +1,121 @@
+package bot
+
+// IRVRound описывает один раунд подсчета по методу мгновенного второго тура (IRV)
+type IRVRound struct {
+	Counts     map[int64]int // Голоса за варианты, оставшиеся в этом раунде (optionID -> голоса)
+	Eliminated []int64       // Варианты, выбывшие по итогам раунда
+	Exhausted  int           // Бюллетени, в которых не осталось ни одного действующего варианта
+}
+
+// IRVResult результат подсчета рейтингового голосования
+type IRVResult struct {
+	Rounds  []IRVRound
+	Winners []int64 // Один победитель, несколько — при ничьей, пусто — если бюллетеней нет
+}
+
+// TallyIRV подсчитывает рейтинговое голосование методом мгновенного второго тура.
+//
+// options — варианты голосования в порядке отображения, ballots — бюллетени,
+// каждый из которых перечисляет optionID в порядке предпочтения. Неизвестные
+// варианты в бюллетенях игнорируются, неполные бюллетени допустимы.
+//
+// В каждом раунде бюллетень отдается первому по предпочтению действующему варианту.
+// Вариант, набравший больше половины действующих бюллетеней, побеждает. Иначе
+// выбывает вариант с наименьшим числом голосов; равенство разрешается по результатам
+// предыдущих раундов, а затем в пользу варианта, стоящего выше в списке.
+// Если у всех оставшихся вариантов поровну голосов, объявляется ничья.
+func TallyIRV(options []int64, ballots [][]int64) IRVResult {
+	var result IRVResult
+	if len(options) == 0 || len(ballots) == 0 {
+		return result
+	}
+
+	active := make(map[int64]bool, len(options))
+	for _, optionID := range options {
+		active[optionID] = true
+	}
+
+	for {
+		round := IRVRound{Counts: make(map[int64]int, len(active))}
+		for optionID := range active {
+			round.Counts[optionID] = 0
+		}
+
+		for _, ballot := range ballots {
+			counted := false
+			for _, optionID := range ballot {
+				if active[optionID] {
+					round.Counts[optionID]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				round.Exhausted++
+			}
+		}
+
+		continuing := len(ballots) - round.Exhausted
+		remaining := activeInOrder(options, active)
+
+		// Абсолютное большинство действующих бюллетеней или последний оставшийся вариант
+		for _, optionID := range remaining {
+			if round.Counts[optionID]*2 > continuing || len(remaining) == 1 {
+				result.Rounds = append(result.Rounds, round)
+				result.Winners = []int64{optionID}
+				return result
+			}
+		}
+
+		lowest := lowestCounted(remaining, round.Counts)
+		if len(lowest) == len(remaining) {
+			// У всех оставшихся вариантов поровну голосов — ничья
+			result.Rounds = append(result.Rounds, round)
+			result.Winners = remaining
+			return result
+		}
+
+		eliminated := breakEliminationTie(lowest, result.Rounds)
+		round.Eliminated = []int64{eliminated}
+		result.Rounds = append(result.Rounds, round)
+		delete(active, eliminated)
+	}
+}
+
+// activeInOrder возвращает действующие варианты в порядке отображения
+func activeInOrder(options []int64, active map[int64]bool) []int64 {
+	remaining := make([]int64, 0, len(active))
+	for _, optionID := range options {
+		if active[optionID] {
+			remaining = append(remaining, optionID)
+		}
+	}
+	return remaining
+}
+
+// lowestCounted возвращает варианты с наименьшим числом голосов (с сохранением порядка)
+func lowestCounted(candidates []int64, counts map[int64]int) []int64 {
+	lowest := make([]int64, 0)
+	minVotes := -1
+	for _, optionID := range candidates {
+		votes := counts[optionID]
+		switch {
+		case minVotes == -1 || votes < minVotes:
+			minVotes = votes
+			lowest = []int64{optionID}
+		case votes == minVotes:
+			lowest = append(lowest, optionID)
+		}
+	}
+	return lowest
+}
+
+// breakEliminationTie выбирает, какой из отстающих вариантов выбывает.
+// Сначала сравниваются результаты предыдущих раундов (от последнего к первому),
+// затем выбывает вариант, стоящий ниже в списке.
+func breakEliminationTie(lowest []int64, previous []IRVRound) int64 {
+	for i := len(previous) - 1; i >= 0 && len(lowest) > 1; i-- {
+		lowest = lowestCounted(lowest, previous[i].Counts)
+	}
+	return lowest[len(lowest)-1]
+}
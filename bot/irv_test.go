@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+)
+
+// repeatBallot возвращает n одинаковых бюллетеней
+func repeatBallot(n int, ballot ...int64) [][]int64 {
+	ballots := make([][]int64, n)
+	for i := range ballots {
+		ballots[i] = ballot
+	}
+	return ballots
+}
+
+// joinBallots склеивает группы бюллетеней
+func joinBallots(groups ...[][]int64) [][]int64 {
+	return slices.Concat(groups...)
+}
+
+func TestTallyIRV(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []int64
+		ballots    [][]int64
+		winners    []int64
+		eliminated [][]int64 // Выбывшие по раундам (число раундов — len(eliminated))
+		exhausted  []int     // Исчерпанные бюллетени по раундам
+	}{
+		{
+			name:       "большинство в первом раунде",
+			options:    []int64{1, 2, 3},
+			ballots:    joinBallots(repeatBallot(2, 1), repeatBallot(1, 2), repeatBallot(1, 1, 3)),
+			winners:    []int64{1},
+			eliminated: [][]int64{nil},
+			exhausted:  []int{0},
+		},
+		{
+			name:    "голоса переходят к следующим предпочтениям",
+			options: []int64{1, 2, 3, 4},
+			ballots: joinBallots(repeatBallot(4, 1), repeatBallot(2, 2, 3), repeatBallot(3, 3, 2), repeatBallot(1, 4, 3)),
+			// 1:4 2:2 3:3 4:1 → выбывает 4; 1:4 2:2 3:4 → выбывает 2; 1:4 3:6 → побеждает 3
+			winners:    []int64{3},
+			eliminated: [][]int64{{4}, {2}, nil},
+			exhausted:  []int{0, 0, 0},
+		},
+		{
+			name:    "исчерпанные бюллетени не входят в порог большинства",
+			options: []int64{1, 2, 3},
+			ballots: joinBallots(repeatBallot(3, 1), repeatBallot(2, 2), repeatBallot(1, 3)),
+			// 3 из 6 — не большинство; после выбывания 3 ее бюллетень исчерпан, 3 из 5 — большинство
+			winners:    []int64{1},
+			eliminated: [][]int64{{3}, nil},
+			exhausted:  []int{0, 1},
+		},
+		{
+			name:    "равенство отстающих разрешается по предыдущим раундам",
+			options: []int64{1, 2, 3, 4},
+			ballots: joinBallots(repeatBallot(5, 1), repeatBallot(2, 2), repeatBallot(3, 3), repeatBallot(1, 4, 2)),
+			// Во втором раунде у 2 и 3 по 3 голоса; в первом у 2 было меньше — выбывает 2, хотя она выше в списке
+			winners:    []int64{1},
+			eliminated: [][]int64{{4}, {2}, nil},
+			exhausted:  []int{0, 0, 3},
+		},
+		{
+			name:       "без предыдущих раундов выбывает вариант ниже в списке",
+			options:    []int64{1, 2, 3},
+			ballots:    joinBallots(repeatBallot(2, 1), repeatBallot(1, 2), repeatBallot(1, 3)),
+			winners:    []int64{1},
+			eliminated: [][]int64{{3}, nil},
+			exhausted:  []int{0, 1},
+		},
+		{
+			name:       "ничья всех оставшихся вариантов",
+			options:    []int64{1, 2, 3},
+			ballots:    [][]int64{{1}, {2}, {3}},
+			winners:    []int64{1, 2, 3},
+			eliminated: [][]int64{nil},
+			exhausted:  []int{0},
+		},
+		{
+			name:    "неизвестные варианты игнорируются",
+			options: []int64{1, 2},
+			ballots: [][]int64{{99, 2}, {2}, {1}, {99}},
+			// Бюллетень только с неизвестным вариантом исчерпан сразу: 2 из 3 — большинство
+			winners:    []int64{2},
+			eliminated: [][]int64{nil},
+			exhausted:  []int{1},
+		},
+		{
+			name:    "без бюллетеней",
+			options: []int64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TallyIRV(tt.options, tt.ballots)
+			if !slices.Equal(result.Winners, tt.winners) {
+				t.Errorf("победители: %v, ожидалось %v", result.Winners, tt.winners)
+			}
+			if len(result.Rounds) != len(tt.eliminated) {
+				t.Fatalf("раундов: %d, ожидалось %d (%+v)", len(result.Rounds), len(tt.eliminated), result.Rounds)
+			}
+			for i, round := range result.Rounds {
+				if !slices.Equal(round.Eliminated, tt.eliminated[i]) {
+					t.Errorf("раунд %d: выбыли %v, ожидалось %v (голоса %v)", i+1, round.Eliminated, tt.eliminated[i], round.Counts)
+				}
+				if round.Exhausted != tt.exhausted[i] {
+					t.Errorf("раунд %d: исчерпано %d, ожидалось %d", i+1, round.Exhausted, tt.exhausted[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBreakEliminationTie(t *testing.T) {
+	tests := []struct {
+		name     string
+		lowest   []int64
+		previous []IRVRound
+		want     int64
+	}{
+		{
+			name:   "без предыдущих раундов — ниже в списке",
+			lowest: []int64{2, 3},
+			want:   3,
+		},
+		{
+			name:     "последний раунд решает",
+			lowest:   []int64{2, 3},
+			previous: []IRVRound{{Counts: map[int64]int{2: 1, 3: 5}}, {Counts: map[int64]int{2: 4, 3: 2}}},
+			want:     3,
+		},
+		{
+			name:     "при равенстве в последнем раунде смотрим более ранний",
+			lowest:   []int64{2, 3},
+			previous: []IRVRound{{Counts: map[int64]int{2: 1, 3: 5}}, {Counts: map[int64]int{2: 3, 3: 3}}},
+			want:     2,
+		},
+		{
+			name:     "равенство во всех раундах — ниже в списке",
+			lowest:   []int64{2, 3, 4},
+			previous: []IRVRound{{Counts: map[int64]int{2: 1, 3: 1, 4: 2}}},
+			want:     3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := breakEliminationTie(tt.lowest, tt.previous); got != tt.want {
+				t.Errorf("выбывает %d, ожидалось %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace префикс имен всех метрик бота
+const metricsNamespace = "wubrg"
+
+// Результаты перерисовки опубликованного сообщения (метка result)
+const (
+	editResultUpdated = "updated" // Сообщение отредактировано
+	editResultSkipped = "skipped" // Хеш не изменился, правка не нужна
+	editResultFailed  = "failed"  // Telegram вернул ошибку (кроме 429 — такие сообщения откладываются)
+)
+
+// Итоги нажатий, не попадающие в vote_log.action (метка outcome)
+const (
+	voteOutcomeClosed   = "closed"    // Голосование завершено
+	voteOutcomeRanked   = "ranked"    // Рейтинговое голосование: варианты ранжируются, а не нажимаются
+	voteOutcomeNotFound = "not_found" // Голосование удалено или вариант не из этого голосования
+	voteOutcomeBanned   = "banned"    // Пользователь заблокирован администратором
+	voteOutcomeError    = "error"     // Ошибка хранилища
+)
+
+// Metrics счетчики и гистограммы бота для Prometheus.
+// Собираются всегда, а видны только после RegisterMetrics.
+type Metrics struct {
+	votes           *prometheus.CounterVec
+	inlineQueries   prometheus.Counter
+	pollsCreated    prometheus.Counter
+	messageEdits    *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	renderDuration  prometheus.Histogram
+}
+
+// newMetrics создает метрики бота
+func newMetrics() *Metrics {
+	return &Metrics{
+		votes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "votes_total",
+			Help:      "Нажатия на варианты и отправленные бюллетени по итогу.",
+		}, []string{"outcome"}),
+		inlineQueries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "inline_queries_total",
+			Help:      "Обработанные inline-запросы.",
+		}),
+		pollsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "polls_created_total",
+			Help:      "Созданные голосования.",
+		}),
+		messageEdits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "poll_message_edits_total",
+			Help:      "Перерисовки опубликованных сообщений: updated, skipped (хеш не изменился), failed.",
+		}, []string{"result"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Длительность обработчиков обновлений Telegram.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler"}),
+		renderDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "poll_render_duration_seconds",
+			Help:      "Длительность перерисовки всех сообщений одного голосования воркером.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+}
+
+// RegisterMetrics регистрирует метрики бота в reg, включая размер очереди
+// обновлений и число незавершенных диалогов (считаются при каждом сборе)
+func (b *Bot) RegisterMetrics(reg prometheus.Registerer) error {
+	m := b.metrics
+	collectors := []prometheus.Collector{
+		m.votes, m.inlineQueries, m.pollsCreated, m.messageEdits, m.handlerDuration, m.renderDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "update_queue_pending",
+			Help:      "Голосования, ожидающие воркера обновлений.",
+		}, func() float64 { return float64(b.updateQueue.Stats().Pending) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "update_queue_delayed",
+			Help:      "Голосования, отложенные до конца интервала перерисовки или паузы Telegram.",
+		}, func() float64 { return float64(b.updateQueue.Stats().Delayed) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "update_queue_dropped_total",
+			Help:      "Запросы на перерисовку, отброшенные после остановки бота: сообщения остались со старыми итогами.",
+		}, func() float64 { return float64(b.updateQueue.Stats().Dropped) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "dialog_sessions",
+			Help:      "Незавершенные диалоги пользователей.",
+		}, func() float64 { return float64(b.dialog.GetAllSessions()) }),
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterPoolMetrics регистрирует в reg состояние пула соединений pgxpool
+func RegisterPoolMetrics(reg prometheus.Registerer, pool *pgxpool.Pool) error {
+	return reg.Register(&poolCollector{pool: pool})
+}
+
+// poolCollector отдает pgxpool.Stat при каждом сборе метрик
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+var (
+	poolTotalConns = prometheus.NewDesc(metricsNamespace+"_db_pool_total_conns",
+		"Открытые соединения пула.", nil, nil)
+	poolAcquiredConns = prometheus.NewDesc(metricsNamespace+"_db_pool_acquired_conns",
+		"Соединения, занятые запросами.", nil, nil)
+	poolIdleConns = prometheus.NewDesc(metricsNamespace+"_db_pool_idle_conns",
+		"Свободные соединения.", nil, nil)
+	poolMaxConns = prometheus.NewDesc(metricsNamespace+"_db_pool_max_conns",
+		"Максимальный размер пула.", nil, nil)
+	poolAcquires = prometheus.NewDesc(metricsNamespace+"_db_pool_acquires_total",
+		"Успешные получения соединения из пула.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc(metricsNamespace+"_db_pool_empty_acquires_total",
+		"Получения соединения, которым пришлось ждать или открывать новое соединение.", nil, nil)
+	poolAcquireWait = prometheus.NewDesc(metricsNamespace+"_db_pool_acquire_wait_seconds_total",
+		"Суммарное время ожидания соединения.", nil, nil)
+)
+
+// Describe реализует prometheus.Collector
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolTotalConns, poolAcquiredConns, poolIdleConns, poolMaxConns,
+		poolAcquires, poolEmptyAcquires, poolAcquireWait} {
+		ch <- d
+	}
+}
+
+// Collect реализует prometheus.Collector
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
+
+// countVote учитывает итог нажатия или бюллетеня
+func (m *Metrics) countVote(outcome string) {
+	m.votes.WithLabelValues(outcome).Inc()
+}
+
+// countEdits учитывает перерисовки опубликованных сообщений
+func (m *Metrics) countEdits(result string, n int) {
+	if n > 0 {
+		m.messageEdits.WithLabelValues(result).Add(float64(n))
+	}
+}
@@ -2,12 +2,17 @@ package bot
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"wubrg-voting-bot/config"
 
 	"gopkg.in/telebot.v4"
 )
@@ -17,9 +22,11 @@ const SameMessageError = "telegram: Bad Request: message is not modified: specif
 // handleCreatePoll запускает диалог создания голосования
 func (b *Bot) handleCreatePoll(c telebot.Context) error {
 	userID := c.Sender().ID
+	if b.isBanned(userID) {
+		return c.Send(bannedMessage)
+	}
 	b.dialog.ResetContext(userID)
 	b.dialog.SetState(userID, StateCreatePollTitle)
-	b.dialog.SetData(userID, "poll_options", []string{}) // Инициализируем список вариантов
 	return c.Send("📊 Создание нового голосования\n\n📝 Шаг 1: Введите заголовок голосования:")
 }
 
@@ -28,20 +35,96 @@ func (b *Bot) handlePollTitleInput(c telebot.Context) error {
 	userID := c.Sender().ID
 	title := c.Text()
 
-	if len(title) < 3 {
-		return c.Send("❌ Заголовок слишком короткий (минимум 3 символа). Попробуйте еще раз:")
+	if err := validatePollTitle(title, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err))
 	}
 
-	if len(title) > 200 {
-		return c.Send("❌ Заголовок слишком длинный (максимум 200 символов). Попробуйте еще раз:")
+	b.dialog.Update(userID, func(ctx *DialogContext) {
+		ctx.Data.Draft.Title = title
+		ctx.State = StateCreatePollDescription
+	})
+
+	return c.Send(fmt.Sprintf("✅ Заголовок сохранен: \"%s\"\n\n"+
+		"📝 Шаг 2: Введите описание голосования\n\n"+
+		"Описание показывается под заголовком. Если оно не нужно, нажмите «Пропустить»:", title), descriptionInputMarkup())
+}
+
+// handlePollDescriptionInput обрабатывает ввод описания голосования
+func (b *Bot) handlePollDescriptionInput(c telebot.Context) error {
+	userID := c.Sender().ID
+	description := strings.TrimSpace(c.Text())
+
+	if err := validatePollDescription(description, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err), descriptionInputMarkup())
 	}
 
-	b.dialog.SetData(userID, "poll_title", title)
-	b.dialog.SetState(userID, StateCreatePollOption)
+	b.dialog.UpdateData(userID, func(data *DialogData) {
+		data.Draft.Description = description
+	})
+	return b.askPollOptions(c, "✅ Описание сохранено")
+}
 
-	return c.Send(fmt.Sprintf("✅ Заголовок сохранен: \"%s\"\n\n"+
-		"📝 Шаг 2: Добавьте варианты ответа\n\n"+
-		"Введите первый вариант ответа:", title))
+// handlePollDescriptionSkipCallback обрабатывает нажатие кнопки "Пропустить" на шаге описания
+func (b *Bot) handlePollDescriptionSkipCallback(c telebot.Context) error {
+	userID := c.Sender().ID
+	dialogCtx := b.dialog.GetContext(userID)
+
+	if dialogCtx.State != StateCreatePollDescription {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного создания голосования"})
+	}
+
+	c.Respond(&telebot.CallbackResponse{})
+	return b.askPollOptions(c, "⏭ Без описания")
+}
+
+// askPollOptions переводит диалог к вводу вариантов ответа
+func (b *Bot) askPollOptions(c telebot.Context, status string) error {
+	b.dialog.SetState(c.Sender().ID, StateCreatePollOption)
+	return c.Send(status + "\n\n" +
+		"📝 Шаг 3: Добавьте варианты ответа\n\n" +
+		"💡 Начните вариант с эмодзи, например «🔴 Красный», — оно будет отображаться в полосе результатов " +
+		"вместо 👍. Цвета маны: ⚪ 🔵 ⚫ 🔴 🟢\n\n" +
+		"Введите первый вариант ответа:")
+}
+
+// validatePollTitle проверяет длину заголовка голосования в символах
+func validatePollTitle(title string, limits config.Limits) error {
+	length := utf8.RuneCountInString(title)
+	if length < limits.TitleMinLength {
+		return fmt.Errorf("Заголовок слишком короткий (минимум символов: %d)", limits.TitleMinLength)
+	}
+	if length > limits.TitleMaxLength {
+		return fmt.Errorf("Заголовок слишком длинный (максимум символов: %d)", limits.TitleMaxLength)
+	}
+	return nil
+}
+
+// validatePollDescription проверяет длину описания голосования в символах
+func validatePollDescription(description string, limits config.Limits) error {
+	if utf8.RuneCountInString(description) > limits.DescriptionMaxLength {
+		return fmt.Errorf("Описание слишком длинное (максимум символов: %d)", limits.DescriptionMaxLength)
+	}
+	return nil
+}
+
+// validatePollOption проверяет длину варианта ответа в символах
+func validatePollOption(option string, limits config.Limits) error {
+	length := utf8.RuneCountInString(option)
+	if length < 1 {
+		return errors.New("Вариант не может быть пустым")
+	}
+	if length > limits.OptionMaxLength {
+		return fmt.Errorf("Вариант слишком длинный (максимум символов: %d)", limits.OptionMaxLength)
+	}
+	return nil
+}
+
+// descriptionInputMarkup возвращает inline-клавиатуру с кнопкой "Пропустить"
+func descriptionInputMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnSkip := markup.Data("⏭ Пропустить", "poll_description_skip")
+	markup.Inline(markup.Row(btnSkip))
+	return markup
 }
 
 // optionInputMarkup возвращает inline-клавиатуру с кнопкой "Готово"
@@ -61,6 +144,41 @@ func confirmPollMarkup() *telebot.ReplyMarkup {
 	return markup
 }
 
+// voteModeMarkup возвращает inline-клавиатуру выбора режима голосования
+func voteModeMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnSingle := markup.Data("☝️ Один вариант", "poll_mode_single")
+	btnMulti := markup.Data("✅ Несколько вариантов", "poll_mode_multi")
+	btnRanked := markup.Data("🔢 Ранжирование (IRV)", "poll_mode_ranked")
+	markup.Inline(markup.Row(btnSingle), markup.Row(btnMulti), markup.Row(btnRanked))
+	return markup
+}
+
+// maxSelectionsInputMarkup возвращает inline-клавиатуру с кнопкой "Без ограничений"
+func maxSelectionsInputMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnSkip := markup.Data("♾ Без ограничений", "poll_max_skip")
+	markup.Inline(markup.Row(btnSkip))
+	return markup
+}
+
+// anonymityMarkup возвращает inline-клавиатуру выбора видимости голосов
+func anonymityMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnNamed := markup.Data("👤 Открытое", "poll_anon_no")
+	btnAnon := markup.Data("🕶 Анонимное", "poll_anon_yes")
+	markup.Inline(markup.Row(btnNamed, btnAnon))
+	return markup
+}
+
+// deadlineInputMarkup возвращает inline-клавиатуру с кнопкой "Без срока"
+func deadlineInputMarkup() *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	btnSkip := markup.Data("♾ Без срока", "poll_deadline_skip")
+	markup.Inline(markup.Row(btnSkip))
+	return markup
+}
+
 // handlePollConfirmYesCallback обрабатывает нажатие кнопки "Подтвердить" при подтверждении
 func (b *Bot) handlePollConfirmYesCallback(c telebot.Context) error {
 	userID := c.Sender().ID
@@ -70,33 +188,42 @@ func (b *Bot) handlePollConfirmYesCallback(c telebot.Context) error {
 		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного создания голосования"})
 	}
 
-	// Получаем данные голосования
-	titleInterface, _ := b.dialog.GetData(userID, "poll_title")
-	optionsInterface, _ := b.dialog.GetData(userID, "poll_options")
+	// Пользователя могли заблокировать, пока он заполнял мастер
+	if b.isBanned(userID) {
+		b.dialog.ResetContext(userID)
+		c.Respond(&telebot.CallbackResponse{Text: "🚫 Вы заблокированы"})
+		return c.Send(bannedMessage)
+	}
 
-	title := titleInterface.(string)
-	options := optionsInterface.([]string)
+	// Получаем данные голосования
+	draft := b.pollDraft(userID)
 
 	// Получаем username создателя
 	username := c.Sender().Username
 
 	// Сохраняем голосование в БД
 	ctx := context.Background()
-	pollID, err := b.savePollToDB(ctx, userID, username, title, options)
+	pollID, err := b.store.CreatePoll(ctx, userID, username, draft)
 	if err != nil {
-		log.Printf("❌ Ошибка сохранения голосования: %v", err)
+		b.log.Error("failed to create poll", "user_id", userID, "error", err)
 		c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка сохранения"})
 		return c.Send(fmt.Sprintf("❌ Ошибка при сохранении голосования: %v\n\nПопробуйте еще раз позже.", err))
 	}
 
-	log.Printf("✅ Пользователь %d создал голосование ID=%d: %s с %d вариантами", userID, pollID, title, len(options))
+	b.metrics.pollsCreated.Inc()
+	b.log.Info("poll created", "user_id", userID, "poll_id", pollID, "title", draft.Title, "options", len(draft.Options))
 
 	// Формируем сообщение об успехе
 	successMsg := "🎉 Голосование успешно создано!\n\n"
-	successMsg += fmt.Sprintf("📝 %s\n\n", title)
-	for i, option := range options {
+	successMsg += fmt.Sprintf("📝 %s\n", draft.Title)
+	if draft.Description != "" {
+		successMsg += draft.Description + "\n"
+	}
+	successMsg += "\n"
+	for i, option := range draft.Options {
 		successMsg += fmt.Sprintf("%d. %s\n", i+1, option)
 	}
+	successMsg += "\n" + draft.settingsSummary()
 	successMsg += fmt.Sprintf("\n✅ Голосование сохранено в базу данных!\n🆔 ID голосования: %d\n\n", pollID)
 	successMsg += "Используйте /publishpoll " + strconv.FormatInt(pollID, 10) + " чтобы опубликовать голосование в этом чате."
 
@@ -130,8 +257,7 @@ func (b *Bot) handlePollDoneCallback(c telebot.Context) error {
 	}
 
 	// Проверяем количество вариантов
-	optionsInterface, _ := b.dialog.GetData(userID, "poll_options")
-	options := optionsInterface.([]string)
+	options := b.pollDraft(userID).Options
 
 	if len(options) < 2 {
 		return c.Respond(&telebot.CallbackResponse{
@@ -140,106 +266,249 @@ func (b *Bot) handlePollDoneCallback(c telebot.Context) error {
 		})
 	}
 
-	// Переходим к подтверждению
+	// Переходим к выбору режима голосования
+	b.dialog.SetState(userID, StateCreatePollMode)
+	c.Respond(&telebot.CallbackResponse{})
+	return c.Send("📝 Шаг 4: Как участники выбирают варианты?\n\n"+
+		"☝️ Один вариант — классическое голосование\n"+
+		"✅ Несколько вариантов — можно отметить несколько\n"+
+		"🔢 Ранжирование — участники расставляют варианты по порядку в личном чате с ботом, "+
+		"победитель определяется мгновенным вторым туром (IRV)", voteModeMarkup())
+}
+
+// handlePollModeCallback обрабатывает выбор режима голосования (один вариант, несколько или ранжирование)
+func (b *Bot) handlePollModeCallback(c telebot.Context, voteType string, allowMultiple bool) error {
+	userID := c.Sender().ID
+	dialogCtx := b.dialog.GetContext(userID)
+
+	if dialogCtx.State != StateCreatePollMode {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного создания голосования"})
+	}
+
+	b.dialog.UpdateData(userID, func(data *DialogData) {
+		data.Draft.VoteType = voteType
+		data.Draft.AllowMultiple = allowMultiple
+		// Бюллетени рейтингового голосования никогда не показывают имен: голосование сразу
+		// анонимно (это скрывает участников и в выгрузках)
+		data.Draft.IsAnonymous = voteType == VoteTypeRanked
+	})
+	c.Respond(&telebot.CallbackResponse{})
+
+	// Для рейтингового голосования выбор видимости не нужен
+	if voteType == VoteTypeRanked {
+		return b.askPollDeadline(c)
+	}
+	if !allowMultiple {
+		return b.askPollAnonymity(c)
+	}
+
+	b.dialog.SetState(userID, StateCreatePollMaxSelections)
+	return c.Send("🔢 Сколько вариантов максимум можно выбрать?\n\n"+
+		"Введите число или нажмите «Без ограничений»:", maxSelectionsInputMarkup())
+}
+
+// handlePollMaxSelectionsInput обрабатывает ввод ограничения на количество выбранных вариантов
+func (b *Bot) handlePollMaxSelectionsInput(c telebot.Context) error {
+	userID := c.Sender().ID
+	draft := b.pollDraft(userID)
+
+	maxSelections, err := strconv.Atoi(strings.TrimSpace(c.Text()))
+	if err != nil || maxSelections < 2 || maxSelections > len(draft.Options) {
+		return c.Send(fmt.Sprintf("❌ Введите число от 2 до %d:", len(draft.Options)), maxSelectionsInputMarkup())
+	}
+
+	// Ограничение, равное числу вариантов, ничего не ограничивает
+	if maxSelections < len(draft.Options) {
+		b.dialog.UpdateData(userID, func(data *DialogData) {
+			data.Draft.MaxSelections = maxSelections
+		})
+	}
+	return b.askPollAnonymity(c)
+}
+
+// handlePollMaxSelectionsSkipCallback обрабатывает нажатие кнопки "Без ограничений"
+func (b *Bot) handlePollMaxSelectionsSkipCallback(c telebot.Context) error {
+	userID := c.Sender().ID
+	dialogCtx := b.dialog.GetContext(userID)
+
+	if dialogCtx.State != StateCreatePollMaxSelections {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного создания голосования"})
+	}
+
+	c.Respond(&telebot.CallbackResponse{})
+	return b.askPollAnonymity(c)
+}
+
+// askPollAnonymity переводит диалог к выбору видимости голосов
+func (b *Bot) askPollAnonymity(c telebot.Context) error {
+	b.dialog.SetState(c.Sender().ID, StateCreatePollAnonymity)
+	return c.Send("📝 Шаг 5: Показывать, кто как проголосовал?\n\n"+
+		"👤 Открытое — под каждым вариантом видны имена проголосовавших\n"+
+		"🕶 Анонимное — видны только количество голосов и проценты", anonymityMarkup())
+}
+
+// handlePollAnonymityCallback обрабатывает выбор видимости голосов
+func (b *Bot) handlePollAnonymityCallback(c telebot.Context, anonymous bool) error {
+	userID := c.Sender().ID
+	dialogCtx := b.dialog.GetContext(userID)
+
+	if dialogCtx.State != StateCreatePollAnonymity {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного создания голосования"})
+	}
+
+	b.dialog.UpdateData(userID, func(data *DialogData) {
+		data.Draft.IsAnonymous = anonymous
+	})
+	c.Respond(&telebot.CallbackResponse{})
+	return b.askPollDeadline(c)
+}
+
+// askPollDeadline переводит диалог к вводу срока окончания голосования
+func (b *Bot) askPollDeadline(c telebot.Context) error {
+	b.dialog.SetState(c.Sender().ID, StateCreatePollDeadline)
+	return c.Send("📝 Шаг 6: Укажите срок окончания голосования\n\n"+
+		"Форматы:\n"+
+		"• через сколько: 30m, 2h, 3d\n"+
+		"• дата и время: 25.12.2025 18:00\n\n"+
+		"Или нажмите «Без срока»:", deadlineInputMarkup())
+}
+
+// handlePollDeadlineInput обрабатывает ввод срока окончания голосования
+func (b *Bot) handlePollDeadlineInput(c telebot.Context) error {
+	userID := c.Sender().ID
+
+	expiresAt, err := parseDeadline(c.Text(), time.Now())
+	if err != nil {
+		return c.Send(fmt.Sprintf("❌ Некорректный срок: %v. Попробуйте еще раз:", err), deadlineInputMarkup())
+	}
+
+	b.dialog.Update(userID, func(ctx *DialogContext) {
+		ctx.Data.Draft.ExpiresAt = &expiresAt
+		ctx.State = StateCreatePollConfirm
+	})
+	return b.showPollPreview(c)
+}
+
+// handlePollDeadlineSkipCallback обрабатывает нажатие кнопки "Без срока"
+func (b *Bot) handlePollDeadlineSkipCallback(c telebot.Context) error {
+	userID := c.Sender().ID
+	dialogCtx := b.dialog.GetContext(userID)
+
+	if dialogCtx.State != StateCreatePollDeadline {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного создания голосования"})
+	}
+
 	b.dialog.SetState(userID, StateCreatePollConfirm)
 	c.Respond(&telebot.CallbackResponse{})
 	return b.showPollPreview(c)
 }
 
+// PollDraft содержит параметры голосования, собранные мастером /createpoll
+type PollDraft struct {
+	Title         string     `json:"title,omitempty"`
+	Description   string     `json:"description,omitempty"` // Описание ("" — без описания)
+	Options       []string   `json:"options,omitempty"`
+	VoteType      string     `json:"vote_type,omitempty"`      // VoteTypePlurality или VoteTypeRanked
+	AllowMultiple bool       `json:"allow_multiple,omitempty"` // Можно выбрать несколько вариантов
+	MaxSelections int        `json:"max_selections,omitempty"` // Максимум выбранных вариантов (0 — без ограничений)
+	IsAnonymous   bool       `json:"is_anonymous,omitempty"`   // Скрывать имена проголосовавших
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`     // Срок окончания (nil — без срока)
+}
+
+// pollDraft собирает черновик голосования из контекста диалога
+func (b *Bot) pollDraft(userID int64) PollDraft {
+	draft := b.dialog.GetData(userID).Draft
+	if draft.VoteType == "" {
+		draft.VoteType = VoteTypePlurality
+	}
+	return draft
+}
+
+// settingsSummary форматирует режим и срок голосования для превью и итогового сообщения
+func (d PollDraft) settingsSummary() string {
+	summary := ""
+	switch {
+	case d.VoteType == VoteTypeRanked:
+		summary += "🔢 Рейтинговое голосование (IRV)\n"
+	case d.AllowMultiple && d.MaxSelections > 0:
+		summary += fmt.Sprintf("☑️ Можно выбрать до %d вариантов\n", d.MaxSelections)
+	case d.AllowMultiple:
+		summary += "☑️ Можно выбрать несколько вариантов\n"
+	default:
+		summary += "☝️ Можно выбрать один вариант\n"
+	}
+
+	// Имена в рейтинговом голосовании не показываются, видимость не выбирается
+	switch {
+	case d.VoteType == VoteTypeRanked:
+	case d.IsAnonymous:
+		summary += "🕶 Анонимное голосование\n"
+	default:
+		summary += "👤 Открытое голосование\n"
+	}
+
+	if d.ExpiresAt != nil {
+		summary += fmt.Sprintf("⏰ Завершится: %s\n", d.ExpiresAt.Format(deadlineLayout))
+	} else {
+		summary += "♾ Без срока окончания\n"
+	}
+	return summary
+}
+
 // handlePollOptionInput обрабатывает ввод вариантов голосования
 func (b *Bot) handlePollOptionInput(c telebot.Context) error {
 	userID := c.Sender().ID
 	option := c.Text()
 
 	// Валидация варианта
-	if len(option) < 1 {
-		return c.Send("❌ Вариант не может быть пустым. Попробуйте еще раз:")
-	}
-
-	if len(option) > 100 {
-		return c.Send("❌ Вариант слишком длинный (максимум 100 символов). Попробуйте еще раз:")
+	if err := validatePollOption(option, b.limits); err != nil {
+		return c.Send(fmt.Sprintf("❌ %v. Попробуйте еще раз:", err))
 	}
 
 	// Добавляем вариант
-	optionsInterface, _ := b.dialog.GetData(userID, "poll_options")
-	options := optionsInterface.([]string)
-	options = append(options, option)
-	b.dialog.SetData(userID, "poll_options", options)
+	var optionNumber int
+	b.dialog.UpdateData(userID, func(data *DialogData) {
+		data.Draft.Options = append(data.Draft.Options, option)
+		optionNumber = len(data.Draft.Options)
+	})
 
-	optionNumber := len(options)
+	added := fmt.Sprintf("✅ Вариант %d добавлен: \"%s\"", optionNumber, option)
+	if emoji := optionEmoji(option); emoji != "" {
+		added += fmt.Sprintf("\n%s — эмодзи для полосы результатов", emoji)
+	}
 
-	return c.Send(fmt.Sprintf("✅ Вариант %d добавлен: \"%s\"\n\n"+
+	return c.Send(fmt.Sprintf("%s\n\n"+
 		"Всего вариантов: %d\n\n"+
 		"Введите следующий вариант или нажмите «Готово» для завершения:",
-		optionNumber, option, optionNumber), optionInputMarkup())
+		added, optionNumber), optionInputMarkup())
 }
 
 // showPollPreview показывает превью голосования перед созданием
 func (b *Bot) showPollPreview(c telebot.Context) error {
 	userID := c.Sender().ID
 
-	titleInterface, _ := b.dialog.GetData(userID, "poll_title")
-	optionsInterface, _ := b.dialog.GetData(userID, "poll_options")
-
-	title := titleInterface.(string)
-	options := optionsInterface.([]string)
+	draft := b.pollDraft(userID)
 
 	preview := fmt.Sprintf("📊 Превью голосования:\n\n"+
 		"━━━━━━━━━━━━━━━━━━━━\n"+
-		"📝 %s\n"+
-		"━━━━━━━━━━━━━━━━━━━━\n\n", title)
+		"📝 %s\n", draft.Title)
+	if draft.Description != "" {
+		preview += draft.Description + "\n"
+	}
+	preview += "━━━━━━━━━━━━━━━━━━━━\n\n"
 
-	for i, option := range options {
+	for i, option := range draft.Options {
 		preview += fmt.Sprintf("%d. %s\n", i+1, option)
 	}
 
+	preview += "\n" + draft.settingsSummary()
+
 	preview += "\n━━━━━━━━━━━━━━━━━━━━\n\n" +
 		"Все верно?"
 
 	return c.Send(preview, confirmPollMarkup())
 }
 
-// savePollToDB сохраняет голосование в базу данных
-func (b *Bot) savePollToDB(ctx context.Context, creatorID int64, creatorUsername string, title string, options []string) (int64, error) {
-	// Начинаем транзакцию
-	tx, err := b.db.Begin(ctx)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
-	}
-	defer tx.Rollback(ctx)
-
-	// Вставляем голосование
-	var pollID int64
-	err = tx.QueryRow(ctx,
-		`INSERT INTO voting.polls (title, creator_telegram_id, creator_username, is_active, created_at, updated_at)
-		 VALUES ($1, $2, $3, true, NOW(), NOW())
-		 RETURNING id`,
-		title, creatorID, creatorUsername,
-	).Scan(&pollID)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка создания голосования: %w", err)
-	}
-
-	// Вставляем варианты ответов
-	for _, option := range options {
-		_, err = tx.Exec(ctx,
-			`INSERT INTO voting.poll_options (poll_id, option_text, created_at)
-			 VALUES ($1, $2, NOW())`,
-			pollID, option,
-		)
-		if err != nil {
-			return 0, fmt.Errorf("ошибка добавления варианта '%s': %w", option, err)
-		}
-	}
-
-	// Коммитим транзакцию
-	if err = tx.Commit(ctx); err != nil {
-		return 0, fmt.Errorf("ошибка фиксации транзакции: %w", err)
-	}
-
-	return pollID, nil
-}
-
 // PollOption представляет вариант ответа в голосовании
 type PollOption struct {
 	ID    int64
@@ -248,6 +517,40 @@ type PollOption struct {
 	Votes []Vote
 }
 
+// maxOptionEmojiRunes ограничивает длину эмодзи-префикса (ZWJ-последовательности занимают до ~10 рун)
+const maxOptionEmojiRunes = 10
+
+// optionEmoji возвращает эмодзи-префикс варианта ("🔴 Красный" -> "🔴") или "", если его нет.
+// Префиксом считается первое слово, целиком состоящее из символов эмодзи, после которого идет текст.
+// Сам префикс остается в тексте варианта, а эмодзи сохраняется в poll_options.emoji.
+func optionEmoji(text string) string {
+	prefix, rest, ok := strings.Cut(strings.TrimSpace(text), " ")
+	if !ok || strings.TrimSpace(rest) == "" || utf8.RuneCountInString(prefix) > maxOptionEmojiRunes {
+		return ""
+	}
+
+	hasSymbol := false
+	keycap := strings.ContainsRune(prefix, '\u20E3')
+	for _, r := range prefix {
+		switch {
+		case unicode.Is(unicode.So, r):
+			hasSymbol = true
+		case r == '\u20E3':
+			// Комбинирующая рамка keycap: 1️⃣, #️⃣
+			hasSymbol = true
+		case unicode.In(r, unicode.Sk, unicode.Mn, unicode.Me, unicode.Cf):
+			// Модификаторы тона кожи, вариационные селекторы, ZWJ
+		case keycap && (unicode.IsDigit(r) || r == '#' || r == '*'):
+		default:
+			return ""
+		}
+	}
+	if !hasSymbol {
+		return ""
+	}
+	return prefix
+}
+
 // Vote представляет один голос
 type Vote struct {
 	UserID    int64
@@ -256,12 +559,52 @@ type Vote struct {
 	LastName  string
 }
 
+// voterFromUser возвращает данные проголосовавшего пользователя Telegram
+func voterFromUser(user *telebot.User) Vote {
+	return Vote{
+		UserID:    user.ID,
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}
+}
+
 // PollData представляет данные голосования
 type PollData struct {
-	ID         int64
-	Title      string
-	Options    []PollOption
-	TotalVotes int
+	ID              int64
+	Title           string
+	Description     string // Описание ("" — без описания)
+	CreatorID       int64
+	CreatorUsername string // Username автора без @ ("" — не задан)
+	IsActive        bool
+	ExpiresAt       *time.Time
+	AllowMultiple   bool // Можно выбрать несколько вариантов
+	MaxSelections   int  // Максимум выбранных вариантов (0 — без ограничений)
+	IsAnonymous     bool // Имена проголосовавших не показываются
+	VoteType        string
+	Options         []PollOption
+	Ballots         [][]int64 // Бюллетени рейтингового голосования (optionID в порядке предпочтения)
+	TotalVotes      int       // Всего голосов (в режиме нескольких вариантов — больше числа участников)
+	TotalVoters     int       // Число проголосовавших пользователей
+}
+
+// countVoters пересчитывает число уникальных проголосовавших по голосам вариантов
+func (p *PollData) countVoters() {
+	voters := make(map[int64]struct{})
+	for _, opt := range p.Options {
+		for _, vote := range opt.Votes {
+			voters[vote.UserID] = struct{}{}
+		}
+	}
+	p.TotalVoters = len(voters)
+}
+
+// IsClosed сообщает, завершено ли голосование (вручную или по истечении срока)
+func (p *PollData) IsClosed() bool {
+	if !p.IsActive {
+		return true
+	}
+	return p.ExpiresAt != nil && !p.ExpiresAt.After(time.Now())
 }
 
 // handleListPolls показывает список активных голосований пользователя
@@ -269,37 +612,11 @@ func (b *Bot) handleListPolls(c telebot.Context) error {
 	ctx := context.Background()
 	userID := c.Sender().ID
 
-	rows, err := b.db.Query(ctx,
-		`SELECT id, title, created_at 
-		 FROM voting.polls 
-		 WHERE is_active = true AND creator_telegram_id = $1
-		 ORDER BY created_at DESC 
-		 LIMIT 10`,
-		userID)
+	polls, err := b.store.ListActivePolls(ctx, userID, b.limits.ListPolls)
 	if err != nil {
-		log.Printf("❌ Ошибка получения списка голосований: %v", err)
+		b.log.Error("failed to list polls", "user_id", userID, "error", err)
 		return c.Send("❌ Ошибка получения списка голосований")
 	}
-	defer rows.Close()
-
-	var polls []struct {
-		ID        int64
-		Title     string
-		CreatedAt time.Time
-	}
-
-	for rows.Next() {
-		var poll struct {
-			ID        int64
-			Title     string
-			CreatedAt time.Time
-		}
-		if err := rows.Scan(&poll.ID, &poll.Title, &poll.CreatedAt); err != nil {
-			log.Printf("❌ Ошибка чтения данных голосования: %v", err)
-			continue
-		}
-		polls = append(polls, poll)
-	}
 
 	if len(polls) == 0 {
 		return c.Send("📊 У вас нет активных голосований.\n\nИспользуйте /createpoll чтобы создать новое.")
@@ -315,112 +632,50 @@ func (b *Bot) handleListPolls(c telebot.Context) error {
 	return c.Send(msg)
 }
 
-// getPollData получает данные голосования из БД одним запросом с JOIN
-func (b *Bot) getPollData(ctx context.Context, pollID int64) (*PollData, error) {
-	// Получаем всё одним запросом с JOIN
-	rows, err := b.db.Query(ctx,
-		`SELECT 
-		     p.id, p.title,
-		     po.id as option_id, po.option_text, po.emoji,
-		     v.user_telegram_id, v.user_username, v.user_first_name, v.user_last_name
-		 FROM voting.polls p
-		 LEFT JOIN voting.poll_options po ON po.poll_id = p.id
-		 LEFT JOIN voting.votes v ON v.poll_id = p.id AND v.option_id = po.id
-		 WHERE p.id = $1 AND p.is_active = true
-		 ORDER BY po.id, v.voted_at`,
-		pollID)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка получения данных голосования: %w", err)
-	}
-	defer rows.Close()
-
-	var poll *PollData
-	optionMap := make(map[int64]*PollOption)
-
-	for rows.Next() {
-		var pollIDResult int64
-		var title string
-		var optionID *int64
-		var optionText *string
-		var emoji *string
-		var voteUserID *int64
-		var voteUsername *string
-		var voteFirstName *string
-		var voteLastName *string
-
-		if err := rows.Scan(&pollIDResult, &title,
-			&optionID, &optionText, &emoji,
-			&voteUserID, &voteUsername, &voteFirstName, &voteLastName); err != nil {
-			return nil, err
-		}
+// formatPollMessage форматирует голосование в красивый текст
+func formatPollMessage(poll *PollData) string {
+	msg := poll.Title
+	if poll.Description != "" {
+		msg += "\n" + poll.Description
+	}
 
-		// Инициализируем poll один раз
-		if poll == nil {
-			poll = &PollData{
-				ID:      pollIDResult,
-				Title:   title,
-				Options: make([]PollOption, 0),
-			}
+	closed := poll.IsClosed()
+	winners := make(map[int64]bool)
+	if closed {
+		msg += "\n🔒 Голосование завершено"
+		for _, opt := range pollWinners(poll) {
+			winners[opt.ID] = true
 		}
+	} else if poll.ExpiresAt != nil {
+		msg += fmt.Sprintf("\n⏰ Завершится: %s", poll.ExpiresAt.Format(deadlineLayout))
+	}
 
-		// Добавляем вариант, если есть
-		if optionID != nil && optionText != nil {
-			option, exists := optionMap[*optionID]
-			if !exists {
-				// Создаём новый вариант
-				emojiValue := "👍"
-				if emoji != nil && *emoji != "" {
-					emojiValue = *emoji
-				}
-				newOption := PollOption{
-					ID:    *optionID,
-					Text:  *optionText,
-					Emoji: emojiValue,
-					Votes: make([]Vote, 0),
-				}
-				poll.Options = append(poll.Options, newOption)
-				// Сохраняем указатель на последний добавленный вариант
-				optionMap[*optionID] = &poll.Options[len(poll.Options)-1]
-				option = optionMap[*optionID]
-			}
-
-			// Добавляем голос, если есть
-			if voteUserID != nil {
-				vote := Vote{
-					UserID: *voteUserID,
-				}
-				if voteUsername != nil {
-					vote.Username = *voteUsername
-				}
-				if voteFirstName != nil {
-					vote.FirstName = *voteFirstName
-				}
-				if voteLastName != nil {
-					vote.LastName = *voteLastName
-				}
-				option.Votes = append(option.Votes, vote)
-				poll.TotalVotes++
-			}
+	if poll.VoteType == VoteTypeRanked {
+		msg += "\n🔢 Рейтинговое голосование: расставьте варианты по порядку"
+		msg += "\n" + formatRankedResults(poll)
+		if closed {
+			return msg + fmt.Sprintf("\n👥 %d people voted.", poll.TotalVoters)
 		}
+		return msg + fmt.Sprintf("\n\n👥 %d people voted so far.", poll.TotalVoters)
 	}
 
-	if poll == nil {
-		return nil, fmt.Errorf("голосование не найдено")
+	if poll.AllowMultiple && poll.MaxSelections > 0 {
+		msg += fmt.Sprintf("\n☑️ Можно выбрать до %d вариантов", poll.MaxSelections)
+	} else if poll.AllowMultiple {
+		msg += "\n☑️ Можно выбрать несколько вариантов"
 	}
 
-	return poll, nil
-}
-
-// formatPollMessage форматирует голосование в красивый текст
-func formatPollMessage(poll *PollData) string {
-	// Получаем текущую дату
-	msg := fmt.Sprintf(poll.Title)
+	if poll.IsAnonymous {
+		msg += "\n🕶 Анонимное голосование"
+	}
 
 	for _, opt := range poll.Options {
+		// Процент считается от числа проголосовавших, а не голосов:
+		// при множественном выборе сумма процентов может превышать 100
 		voteCount := len(opt.Votes)
 		percentage := 0
-		if poll.TotalVotes > 0 {
-			percentage = (voteCount * 100) / poll.TotalVotes
+		if poll.TotalVoters > 0 {
+			percentage = (voteCount * 100) / poll.TotalVoters
 		}
 
 		// Вычисляем количество эмодзи (примерно 1 эмодзи на 6-7%)
@@ -433,11 +688,20 @@ func formatPollMessage(poll *PollData) string {
 			thumbs = opt.Emoji
 		}
 
-		msg += fmt.Sprintf("\n%s – %d\n", opt.Text, voteCount)
+		if winners[opt.ID] {
+			msg += fmt.Sprintf("\n🏆 %s – %d\n", opt.Text, voteCount)
+		} else {
+			msg += fmt.Sprintf("\n%s – %d\n", opt.Text, voteCount)
+		}
 
 		if voteCount > 0 {
 			msg += fmt.Sprintf("%s %d%%\n", thumbs, percentage)
 
+			// В анонимном голосовании показываем только количество и проценты
+			if poll.IsAnonymous {
+				continue
+			}
+
 			// Список пользователей
 			usernames := make([]string, 0)
 			for _, vote := range opt.Votes {
@@ -456,20 +720,86 @@ func formatPollMessage(poll *PollData) string {
 		}
 	}
 
-	msg += fmt.Sprintf("\n\n👥 %d people voted so far.", poll.TotalVotes)
+	if closed {
+		msg += "\n\n" + formatPollOutcome(pollWinners(poll))
+		msg += fmt.Sprintf("\n👥 %d people voted.", poll.TotalVoters)
+	} else {
+		msg += fmt.Sprintf("\n\n👥 %d people voted so far.", poll.TotalVoters)
+	}
 
 	return msg
 }
 
+// pollWinners возвращает варианты с наибольшим числом голосов.
+// Несколько вариантов означают ничью; пустой результат — голосов не было.
+func pollWinners(poll *PollData) []PollOption {
+	maxVotes := 0
+	for _, opt := range poll.Options {
+		if len(opt.Votes) > maxVotes {
+			maxVotes = len(opt.Votes)
+		}
+	}
+	if maxVotes == 0 {
+		return nil
+	}
+
+	winners := make([]PollOption, 0)
+	for _, opt := range poll.Options {
+		if len(opt.Votes) == maxVotes {
+			winners = append(winners, opt)
+		}
+	}
+	return winners
+}
+
+// formatPollOutcome форматирует итог завершенного голосования
+func formatPollOutcome(winners []PollOption) string {
+	switch len(winners) {
+	case 0:
+		return "🤷 Итог: голосов нет"
+	case 1:
+		return fmt.Sprintf("🏆 Победитель: %s", winners[0].Text)
+	default:
+		names := make([]string, 0, len(winners))
+		for _, opt := range winners {
+			names = append(names, opt.Text)
+		}
+		return fmt.Sprintf("🤝 Ничья: %s", strings.Join(names, ", "))
+	}
+}
+
+// pollMarkup возвращает inline-клавиатуру с кнопками голосования.
+// Для завершенного голосования возвращает nil — клавиатура убирается из сообщения.
+func pollMarkup(poll *PollData) *telebot.ReplyMarkup {
+	if poll.IsClosed() {
+		return nil
+	}
+
+	markup := &telebot.ReplyMarkup{}
+
+	// В рейтинговом голосовании варианты ранжируются в личном чате с ботом
+	if poll.VoteType == VoteTypeRanked {
+		btn := markup.Data("🔢 Ранжировать варианты", "rank", strconv.FormatInt(poll.ID, 10))
+		markup.Inline(markup.Row(btn))
+		return markup
+	}
+
+	rows := make([]telebot.Row, 0)
+	for _, opt := range poll.Options {
+		btn := markup.Data(opt.Text, "vote", strconv.FormatInt(poll.ID, 10), strconv.FormatInt(opt.ID, 10))
+		rows = append(rows, markup.Row(btn))
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
 // handlePublishPoll публикует голосование в чат
 func (b *Bot) handlePublishPoll(c telebot.Context) error {
 	// Парсим ID голосования из команды
-	args := strings.Fields(c.Text())
-	if len(args) < 2 {
+	pollID, ok, err := parsePollIDArg(c.Text())
+	if !ok {
 		return c.Send("❌ Укажите ID голосования.\n\nИспользование: /publishpoll <ID>\n\nПосмотрите список голосований: /listpolls")
 	}
-
-	pollID, err := strconv.ParseInt(args[1], 10, 64)
 	if err != nil {
 		return c.Send("❌ Некорректный ID голосования")
 	}
@@ -477,57 +807,113 @@ func (b *Bot) handlePublishPoll(c telebot.Context) error {
 	ctx := context.Background()
 	userID := c.Sender().ID
 
-	// Проверяем, что пользователь является владельцем голосования
-	var creatorID int64
-	err = b.db.QueryRow(ctx,
-		`SELECT creator_telegram_id FROM voting.polls WHERE id = $1 AND is_active = true`,
-		pollID).Scan(&creatorID)
+	poll, err := b.store.GetPoll(ctx, pollID)
 	if err != nil {
-		log.Printf("❌ Ошибка проверки владельца голосования: %v", err)
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Голосование не найдено или не активно")
+	}
+	if !poll.IsActive {
 		return c.Send("❌ Голосование не найдено или не активно")
 	}
 
-	if creatorID != userID {
-		log.Printf("⚠️ Пользователь %d попытался опубликовать чужое голосование %d (владелец: %d)", userID, pollID, creatorID)
+	// Проверяем, что пользователь является владельцем голосования
+	if poll.CreatorID != userID {
+		b.log.Warn("publish of foreign poll denied", "user_id", userID, "poll_id", pollID, "owner_id", poll.CreatorID)
 		return c.Send("❌ Вы можете публиковать только свои голосования.\n\nПосмотрите список своих голосований: /listpolls")
 	}
 
-	poll, err := b.getPollData(ctx, pollID)
+	// Отправляем голосование вместе с кнопками
+	msg := formatPollMessage(poll)
+	sentMsg, err := c.Bot().Send(c.Chat(), msg, pollMarkup(poll))
 	if err != nil {
-		log.Printf("❌ Ошибка получения данных голосования: %v", err)
-		return c.Send(fmt.Sprintf("❌ Ошибка: %v", err))
+		b.log.Error("failed to send poll", "poll_id", pollID, "chat_id", c.Chat().ID, "error", err)
+		return c.Send("❌ Ошибка отправки голосования")
 	}
 
-	// Создаем inline-кнопки для голосования
-	markup := &telebot.ReplyMarkup{}
-	rows := make([]telebot.Row, 0)
+	// Сохраняем информацию о публикации в БД
+	err = b.store.AddPollChat(ctx, pollID, c.Chat().ID, int64(sentMsg.ID))
+	if err != nil {
+		b.log.Error("failed to save poll chat", "poll_id", pollID, "chat_id", c.Chat().ID, "error", err)
+	}
 
-	for _, opt := range poll.Options {
-		btn := markup.Data(opt.Text, "vote", strconv.FormatInt(pollID, 10), strconv.FormatInt(opt.ID, 10))
-		rows = append(rows, markup.Row(btn))
+	b.log.Info("poll published", "poll_id", pollID, "chat_id", c.Chat().ID)
+	return nil
+}
+
+// parsePollIDArg извлекает ID голосования из аргумента команды вида "/command <ID>"
+func parsePollIDArg(text string) (int64, bool, error) {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return 0, false, nil
 	}
-	markup.Inline(rows...)
+	pollID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, true, err
+	}
+	return pollID, true, nil
+}
 
-	// Отправляем голосование
-	msg := formatPollMessage(poll)
-	sentMsg, err := c.Bot().Send(c.Chat(), msg, markup)
+// handleClosePoll завершает голосование досрочно (только владелец)
+func (b *Bot) handleClosePoll(c telebot.Context) error {
+	return b.setPollActive(c, false)
+}
+
+// handleReopenPoll возобновляет завершенное голосование (только владелец)
+func (b *Bot) handleReopenPoll(c telebot.Context) error {
+	return b.setPollActive(c, true)
+}
+
+// setPollActive меняет статус голосования и ставит все его публикации в очередь на перерисовку
+func (b *Bot) setPollActive(c telebot.Context, active bool) error {
+	command := "/closepoll"
+	if active {
+		command = "/reopenpoll"
+	}
+
+	pollID, ok, err := parsePollIDArg(c.Text())
+	if !ok {
+		return c.Send(fmt.Sprintf("❌ Укажите ID голосования.\n\nИспользование: %s <ID>\n\nПосмотрите список голосований: /listpolls", command))
+	}
 	if err != nil {
-		log.Printf("❌ Ошибка отправки голосования: %v", err)
-		return c.Send("❌ Ошибка отправки голосования")
+		return c.Send("❌ Некорректный ID голосования")
 	}
 
-	// Сохраняем информацию о публикации в БД
-	_, err = b.db.Exec(ctx,
-		`INSERT INTO voting.poll_chats (poll_id, chat_id, message_id) 
-		 VALUES ($1, $2, $3)
-		 ON CONFLICT (poll_id, chat_id, message_id) DO NOTHING`,
-		pollID, c.Chat().ID, sentMsg.ID)
+	ctx := context.Background()
+	userID := c.Sender().ID
+
+	// Проверяем владельца
+	current, err := b.store.GetPoll(ctx, pollID)
 	if err != nil {
-		log.Printf("❌ Ошибка сохранения информации о публикации: %v", err)
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Голосование не найдено")
 	}
 
-	log.Printf("✅ Голосование %d опубликовано в чат %d", pollID, c.Chat().ID)
-	return nil
+	if current.CreatorID != userID {
+		b.log.Warn("status change of foreign poll denied", "user_id", userID, "poll_id", pollID, "owner_id", current.CreatorID)
+		return c.Send("❌ Вы можете управлять только своими голосованиями.")
+	}
+
+	if err := b.store.SetPollActive(ctx, pollID, active); err != nil {
+		b.log.Error("failed to set poll status", "poll_id", pollID, "active", active, "error", err)
+		return c.Send("❌ Ошибка изменения статуса голосования")
+	}
+
+	// Перерисовываем все опубликованные копии (кнопки убираются или возвращаются)
+	b.updateQueue.Schedule(pollID)
+
+	if active {
+		b.log.Info("poll reopened", "user_id", userID, "poll_id", pollID)
+		return c.Send(fmt.Sprintf("🔓 Голосование %d снова открыто.", pollID))
+	}
+
+	poll, err := b.store.GetPoll(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send(fmt.Sprintf("🔒 Голосование %d завершено.", pollID))
+	}
+
+	b.log.Info("poll closed", "user_id", userID, "poll_id", pollID)
+	return c.Send(fmt.Sprintf("🔒 Голосование %d завершено.\n\n%s", pollID, formatPollMessage(poll)))
 }
 
 // handleVote обрабатывает голосование пользователя
@@ -555,47 +941,70 @@ func (b *Bot) handleVote(c telebot.Context) error {
 	user := c.Sender()
 	ctx := context.Background()
 
-	// Начинаем транзакцию
-	tx, err := b.db.Begin(ctx)
-	if err != nil {
-		log.Printf("❌ Ошибка начала транзакции: %v", err)
-		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка обработки голоса"})
+	// Слишком частые нажатия не доходят до транзакции и перерисовки, но остаются в vote_log
+	if !b.voteLimiter.Allow(user.ID, pollID) {
+		if err := b.store.LogThrottledVote(ctx, pollID, optionID, user.ID); err != nil {
+			b.log.Error("failed to log throttled vote", "poll_id", pollID, "user_id", user.ID, "error", err)
+		}
+		b.log.Debug("vote throttled", "poll_id", pollID, "user_id", user.ID)
+		b.metrics.countVote(voteActionThrottled)
+		return c.Respond(&telebot.CallbackResponse{
+			Text:      "🐢 Слишком много нажатий. Подождите пару секунд и попробуйте снова — ваш текущий выбор сохранен.",
+			ShowAlert: true,
+		})
 	}
-	defer tx.Rollback(ctx)
 
-	// Логируем нажатие на кнопку в vote_log (append-only) - в самом начале транзакции
-	_, err = tx.Exec(ctx,
-		`INSERT INTO voting.vote_log (user_telegram_id, poll_id, option_id)
-		 VALUES ($1, $2, $3)`,
-		user.ID, pollID, optionID)
-	if err != nil {
-		log.Printf("❌ Ошибка записи в vote_log: %v", err)
-		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка логирования"})
-	}
-
-	// Сохраняем или обновляем голос
-	_, err = tx.Exec(ctx,
-		`INSERT INTO voting.votes (poll_id, option_id, user_telegram_id, user_username, user_first_name, user_last_name)
-		 VALUES ($1, $2, $3, $4, $5, $6)
-		 ON CONFLICT (poll_id, user_telegram_id) 
-		 DO UPDATE SET option_id = EXCLUDED.option_id, voted_at = NOW()
-		 WHERE votes.option_id != EXCLUDED.option_id`,
-		pollID, optionID, user.ID, user.Username, user.FirstName, user.LastName)
-	if err != nil {
-		log.Printf("❌ Ошибка сохранения голоса: %v", err)
-		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка сохранения голоса"})
+	if b.isBanned(user.ID) {
+		b.metrics.countVote(voteOutcomeBanned)
+		return c.Respond(&telebot.CallbackResponse{Text: bannedMessage, ShowAlert: true})
 	}
 
-	// Фиксируем транзакцию
-	if err = tx.Commit(ctx); err != nil {
-		log.Printf("❌ Ошибка фиксации транзакции: %v", err)
-		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка обработки голоса"})
+	result, err := b.store.CastVote(ctx, pollID, optionID, voterFromUser(user))
+	switch {
+	case errors.Is(err, errPollClosed):
+		b.metrics.countVote(voteOutcomeClosed)
+		return c.Respond(&telebot.CallbackResponse{Text: "🔒 Голосование завершено", ShowAlert: true})
+	case errors.Is(err, errPollRanked):
+		b.metrics.countVote(voteOutcomeRanked)
+		return c.Respond(&telebot.CallbackResponse{Text: "🔢 В этом голосовании варианты нужно ранжировать", ShowAlert: true})
+	case errors.Is(err, errPollNotFound):
+		b.metrics.countVote(voteOutcomeNotFound)
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Голосование не найдено"})
+	case errors.Is(err, errOptionNotFound):
+		b.metrics.countVote(voteOutcomeNotFound)
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Вариант не найден"})
+	case err != nil:
+		b.metrics.countVote(voteOutcomeError)
+		b.log.Error("failed to save vote", "poll_id", pollID, "user_id", user.ID, "error", err)
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка сохранения голоса"})
 	}
+	b.metrics.countVote(result.Outcome.logAction())
 
 	// Планируем обновление всех сообщений этого голосования через очередь
 	b.updateQueue.Schedule(pollID)
 
-	return c.Respond(&telebot.CallbackResponse{Text: "✅ Ваш голос учтен!"})
+	return c.Respond(voteResponse(result))
+}
+
+// voteResponse возвращает всплывающее уведомление для итога нажатия на вариант
+func voteResponse(result VoteResult) *telebot.CallbackResponse {
+	switch result.Outcome {
+	case VoteChanged:
+		return &telebot.CallbackResponse{Text: "🔄 Голос изменен"}
+	case VoteWithdrawn:
+		return &telebot.CallbackResponse{Text: "↩️ Голос отозван"}
+	case VoteSelected:
+		return &telebot.CallbackResponse{Text: "✅ Вариант выбран"}
+	case VoteDeselected:
+		return &telebot.CallbackResponse{Text: "☑️ Выбор снят"}
+	case VoteLimitReached:
+		return &telebot.CallbackResponse{
+			Text:      fmt.Sprintf("⚠️ Можно выбрать не более %d вариантов. Снимите один из выбранных, чтобы выбрать другой.", result.MaxSelections),
+			ShowAlert: true,
+		}
+	default:
+		return &telebot.CallbackResponse{Text: "✅ Ваш голос учтен!"}
+	}
 }
 
 // handleInlineQuery обрабатывает inline-запросы (@bot_name)
@@ -606,152 +1015,68 @@ func (b *Bot) handleInlineQuery(c telebot.Context) error {
 		Start: "createpoll",
 	}
 
+	b.metrics.inlineQueries.Inc()
 	ctx := context.Background()
 
 	// Получаем ID текущего пользователя
 	userID := c.Sender().ID
 
-	// Получаем активные голосования с вариантами и голосами одним запросом (избегаем N+1)
-	rows, err := b.db.Query(ctx,
-		`WITH recent_polls AS (
-		     SELECT id, title, created_at
-		     FROM voting.polls
-		     WHERE is_active = true 
-		       AND creator_telegram_id = $1
-		     ORDER BY created_at DESC
-		     LIMIT 5
-		 )
-		 SELECT 
-		     p.id, p.title, p.created_at,
-		     po.id as option_id, po.option_text, po.emoji,
-		     v.user_telegram_id, v.user_username, v.user_first_name, v.user_last_name
-		 FROM recent_polls p
-		 LEFT JOIN voting.poll_options po ON po.poll_id = p.id
-		 LEFT JOIN voting.votes v ON v.option_id = po.id AND v.poll_id = p.id
-		 ORDER BY p.created_at DESC, po.id, v.voted_at`,
-		userID)
+	// Текст поиска и смещение страницы (NextOffset из предыдущего ответа)
+	searchText := strings.TrimSpace(c.Query().Text)
+	offset := 0
+	if c.Query().Offset != "" {
+		if parsed, err := strconv.Atoi(c.Query().Offset); err == nil && parsed > 0 {
+			offset = parsed
+		}
+	}
+
+	// Берем на одно голосование больше размера страницы, чтобы понять, есть ли следующая
+	polls, err := b.store.SearchActivePolls(ctx, userID, searchText, b.inline.PageSize+1, offset)
 	if err != nil {
-		log.Printf("❌ Ошибка получения списка голосований для inline: %v", err)
+		b.log.Error("failed to search polls for inline query", "user_id", userID, "error", err)
 		return c.Answer(&telebot.QueryResponse{
-			Results:   telebot.Results{},
-			CacheTime: 10,
-			Button:    createPollButton,
+			Results:    telebot.Results{},
+			CacheTime:  b.inline.CacheTime,
+			IsPersonal: true,
+			Button:     createPollButton,
 		})
 	}
-	defer rows.Close()
-
-	// Собираем данные голосований из плоского результата
-	pollsMap := make(map[int64]*PollData)
-	pollsOrder := make([]int64, 0)
-	optionsMap := make(map[int64]*PollOption)
-
-	for rows.Next() {
-		var pollID int64
-		var title string
-		var createdAt time.Time
-		var optionID *int64
-		var optionText *string
-		var emoji *string
-		var voteUserID *int64
-		var voteUsername *string
-		var voteFirstName *string
-		var voteLastName *string
-
-		if err := rows.Scan(&pollID, &title, &createdAt,
-			&optionID, &optionText, &emoji,
-			&voteUserID, &voteUsername, &voteFirstName, &voteLastName); err != nil {
-			log.Printf("❌ Ошибка чтения данных голосования: %v", err)
-			continue
-		}
-
-		// Создаем или получаем голосование
-		poll, exists := pollsMap[pollID]
-		if !exists {
-			poll = &PollData{
-				ID:      pollID,
-				Title:   title,
-				Options: make([]PollOption, 0),
-			}
-			pollsMap[pollID] = poll
-			pollsOrder = append(pollsOrder, pollID)
-		}
-
-		// Добавляем вариант, если есть
-		if optionID != nil && optionText != nil {
-			optionKey := *optionID
-			option, optExists := optionsMap[optionKey]
-			if !optExists {
-				emojiValue := "👍"
-				if emoji != nil && *emoji != "" {
-					emojiValue = *emoji
-				}
-				option = &PollOption{
-					ID:    *optionID,
-					Text:  *optionText,
-					Emoji: emojiValue,
-					Votes: make([]Vote, 0),
-				}
-				poll.Options = append(poll.Options, *option)
-				// Сохраняем указатель на последний добавленный вариант
-				optionsMap[optionKey] = &poll.Options[len(poll.Options)-1]
-				option = optionsMap[optionKey]
-			}
 
-			// Добавляем голос, если есть
-			if voteUserID != nil {
-				vote := Vote{
-					UserID: *voteUserID,
-				}
-				if voteUsername != nil {
-					vote.Username = *voteUsername
-				}
-				if voteFirstName != nil {
-					vote.FirstName = *voteFirstName
-				}
-				if voteLastName != nil {
-					vote.LastName = *voteLastName
-				}
-				option.Votes = append(option.Votes, vote)
-				poll.TotalVotes++
-			}
-		}
+	// Если голосований больше размера страницы, отдаем клиенту смещение следующей
+	nextOffset := ""
+	if len(polls) > b.inline.PageSize {
+		polls = polls[:b.inline.PageSize]
+		nextOffset = strconv.Itoa(offset + b.inline.PageSize)
 	}
 
 	// Формируем результаты для inline-режима
 	results := make(telebot.Results, 0)
 
-	for _, pollID := range pollsOrder {
-		poll := pollsMap[pollID]
-
+	for _, poll := range polls {
 		// Форматируем сообщение голосования
 		pollText := formatPollMessage(poll)
 
-		// Создаем inline-кнопки для голосования
-		markup := &telebot.ReplyMarkup{}
-		btnRows := make([]telebot.Row, 0)
-		for _, opt := range poll.Options {
-			btn := markup.Data(opt.Text, "vote", strconv.FormatInt(poll.ID, 10), strconv.FormatInt(opt.ID, 10))
-			btnRows = append(btnRows, markup.Row(btn))
-		}
-		markup.Inline(btnRows...)
-
 		// Получаем дату создания (можно сохранить в PollData, но для простоты используем текущее время)
 		result := &telebot.ArticleResult{
 			ResultBase: telebot.ResultBase{
 				ID:          strconv.FormatInt(poll.ID, 10),
 				Type:        "article",
-				ReplyMarkup: markup,
+				ReplyMarkup: pollMarkup(poll),
 			},
-			Title: poll.Title,
-			Text:  pollText,
+			Title:       poll.Title,
+			Description: poll.Description,
+			Text:        pollText,
 		}
 
 		results = append(results, result)
 	}
 
-	// Если ничего не найдено, показываем информационное сообщение
-	if len(results) == 0 {
+	// Если ничего не найдено, показываем информационное сообщение (только на первой странице)
+	if len(results) == 0 && offset == 0 {
 		noResultMsg := "📊 Нет активных голосований"
+		if searchText != "" {
+			noResultMsg = fmt.Sprintf("🔍 Ничего не найдено по запросу «%s»", searchText)
+		}
 
 		result := &telebot.ArticleResult{
 			ResultBase: telebot.ResultBase{
@@ -766,9 +1091,11 @@ func (b *Bot) handleInlineQuery(c telebot.Context) error {
 	}
 
 	return c.Answer(&telebot.QueryResponse{
-		Results:   results,
-		CacheTime: 10, // Кешировать на 10 секунд
-		Button:    createPollButton,
+		Results:    results,
+		CacheTime:  b.inline.CacheTime,
+		IsPersonal: true, // Результаты у каждого пользователя свои
+		NextOffset: nextOffset,
+		Button:     createPollButton,
 	})
 }
 
@@ -778,141 +1105,234 @@ func (b *Bot) handleChosenInlineResult(c telebot.Context) error {
 	// Пытаемся получить InlineResult из контекста
 	result := c.InlineResult()
 	if result == nil {
-		log.Printf("⚠️ InlineResult is nil")
+		b.log.Warn("chosen inline result is empty")
 		return nil
 	}
 
 	// ResultID содержит pollID (мы устанавливали его в handleInlineQuery)
 	pollID, err := strconv.ParseInt(result.ResultID, 10, 64)
 	if err != nil {
-		log.Printf("❌ Ошибка парсинга poll ID из inline result: %v", err)
+		b.log.Error("failed to parse poll id from inline result", "result_id", result.ResultID, "error", err)
 		return nil
 	}
 
 	// InlineMessageID - уникальный идентификатор inline-сообщения
 	inlineMessageID := result.MessageID
 	if inlineMessageID == "" {
-		log.Printf("⚠️ InlineMessageID пустой для poll_id=%d", pollID)
+		b.log.Warn("chosen inline result has no inline message id", "poll_id", pollID)
 		return nil
 	}
 
 	ctx := context.Background()
 
 	// Сохраняем информацию об отправке inline-голосования
-	_, err = b.db.Exec(ctx,
-		`INSERT INTO voting.poll_chats (poll_id, inline_message_id, message_hash, created_at) 
-		 VALUES ($1, $2, $3, NOW())
-		 ON CONFLICT (poll_id, inline_message_id) WHERE inline_message_id IS NOT NULL 
-		 DO NOTHING`,
-		pollID, inlineMessageID, uint64(0))
+	err = b.store.AddInlinePollChat(ctx, pollID, inlineMessageID)
 	if err != nil {
-		log.Printf("❌ Ошибка сохранения inline-публикации в poll_chats: %v", err)
+		b.log.Error("failed to save inline poll chat", "poll_id", pollID, "error", err)
 		return nil
 	}
 
-	log.Printf("✅ Inline-голосование %d отправлено пользователем %d (inline_msg_id=%s, hash=%d)",
-		pollID, c.Sender().ID, inlineMessageID, uint64(0))
+	b.log.Info("poll published inline", "poll_id", pollID, "user_id", c.Sender().ID, "inline_message_id", inlineMessageID)
 
 	return nil
 }
 
 // updatePollMessages обновляет все опубликованные сообщения для указанного голосования.
 // Пропускает обновление если хеш сообщения не изменился.
+// Сообщения в разных чатах правятся параллельно (не более EditParallelism чатов),
+// в одном чате — по очереди в пределах editBudget. Если бюджет чата исчерпан или
+// Telegram ответил 429, оставшиеся сообщения обновятся повторным проходом через очередь.
 // Вызывается из воркера очереди обновлений.
 func (b *Bot) updatePollMessages(pollID int64) {
+	// Удаленное голосование заменяется пометкой тем же воркером, чтобы перерисовка,
+	// начатая до удаления, не затерла пометку
+	if chats, deleted := b.takeDeletedPoll(pollID); deleted {
+		b.replaceDeletedPollMessages(pollID, chats)
+		return
+	}
+
 	ctx := context.Background()
+	start := time.Now()
+	log := b.log.With("component", "UpdateWorker", "poll_id", pollID)
 
 	// Получаем актуальные данные голосования
-	poll, err := b.getPollData(ctx, pollID)
+	poll, err := b.store.GetPoll(ctx, pollID)
 	if err != nil {
-		log.Printf("❌ [UpdateWorker] Ошибка получения данных голосования %d: %v", pollID, err)
+		log.Error("failed to get poll", "error", err)
 		return
 	}
 
-	// Форматируем текст и вычисляем хеш
+	// Форматируем текст и клавиатуру и вычисляем хеш
 	msg := formatPollMessage(poll)
-	newHash := int64(FastHash(msg))
 
-	markup := &telebot.ReplyMarkup{}
-	btnRows := make([]telebot.Row, 0)
-	for _, opt := range poll.Options {
-		btn := markup.Data(opt.Text, "vote", strconv.FormatInt(pollID, 10), strconv.FormatInt(opt.ID, 10))
-		btnRows = append(btnRows, markup.Row(btn))
-	}
-	markup.Inline(btnRows...)
+	// Для завершенного голосования markup == nil, и кнопки убираются из сообщения
+	markup := pollMarkup(poll)
+	newHash := pollMessageHash(msg, markup)
 
 	// Получаем все опубликованные сообщения для этого голосования (включая хеш)
-	rows, err := b.db.Query(ctx,
-		`SELECT id, chat_id, message_id, inline_message_id, message_hash FROM voting.poll_chats WHERE poll_id = $1`,
-		pollID)
+	chats, err := b.store.ListPollChats(ctx, pollID)
 	if err != nil {
-		log.Printf("❌ [UpdateWorker] Ошибка получения чатов для голосования %d: %v", pollID, err)
+		log.Error("failed to list poll chats", "error", err)
 		return
 	}
-	defer rows.Close()
 
-	updated := 0
+	// Проверяем хеш — если не изменился, пропускаем обновление
 	skipped := 0
-	for rows.Next() {
-		var rowID int64
-		var chatID *int64
-		var messageID *int64
-		var inlineMessageID *string
-		var messageHash *int64
-
-		if err := rows.Scan(&rowID, &chatID, &messageID, &inlineMessageID, &messageHash); err != nil {
-			log.Printf("❌ [UpdateWorker] Ошибка чтения данных poll_chats: %v", err)
+	changed := make([]PollChat, 0, len(chats))
+	for _, chat := range chats {
+		if chat.MessageHash != nil && *chat.MessageHash == newHash {
+			skipped++
 			continue
 		}
+		changed = append(changed, chat)
+	}
 
-		// Проверяем хеш — если не изменился, пропускаем обновление
-		if messageHash != nil && *messageHash == newHash {
-			skipped++
+	updated, failed, deferred, retryIn := b.editPollChats(ctx, pollID, changed, msg, markup, newHash)
+	if len(deferred) > 0 {
+		b.updateQueue.ScheduleAfter(pollID, retryIn)
+	}
+
+	duration := time.Since(start)
+	b.metrics.countEdits(editResultUpdated, updated)
+	b.metrics.countEdits(editResultSkipped, skipped)
+	b.metrics.countEdits(editResultFailed, failed)
+	b.metrics.renderDuration.Observe(duration.Seconds())
+	log.Info("poll messages rendered",
+		"updated", updated, "skipped", skipped, "failed", failed, "deferred", len(deferred), "duration", duration)
+}
+
+// replaceDeletedPollMessages заменяет сообщения удаленного голосования пометкой без кнопок.
+// Сообщения, отложенные бюджетом правок или ответом 429, остаются за голосованием до повтора.
+func (b *Bot) replaceDeletedPollMessages(pollID int64, chats []PollChat) {
+	updated, failed, deferred, retryIn := b.editPollChats(context.Background(), pollID, chats, deletedPollMessage, nil, 0)
+	if len(deferred) > 0 {
+		b.markPollDeleted(pollID, deferred)
+		b.updateQueue.ScheduleAfter(pollID, retryIn)
+	}
+
+	b.metrics.countEdits(editResultUpdated, updated)
+	b.metrics.countEdits(editResultFailed, failed)
+	b.log.Info("deleted poll messages replaced", "component", "UpdateWorker", "poll_id", pollID,
+		"updated", updated, "failed", failed, "deferred", len(deferred))
+}
+
+// editPollChats правит публикации голосования: разные чаты параллельно (не более
+// editParallelism), сообщения одного чата — по очереди в пределах editBudget.
+// Возвращает публикации, отложенные до retryIn из-за бюджета или ответа 429.
+func (b *Bot) editPollChats(ctx context.Context, pollID int64, chats []PollChat, msg string, markup *telebot.ReplyMarkup, newHash int64) (updated, failed int, deferred []PollChat, retryIn time.Duration) {
+	// Группируем сообщения по чатам
+	var chatKeys []string
+	byChat := make(map[string][]PollChat)
+	for _, chat := range chats {
+		key := pollChatKey(chat)
+		if key == "" {
 			continue
 		}
+		if _, ok := byChat[key]; !ok {
+			chatKeys = append(chatKeys, key)
+		}
+		byChat[key] = append(byChat[key], chat)
+	}
 
-		var editErr error
-		var ok bool
-		if inlineMessageID != nil && *inlineMessageID != "" {
-			// Inline-сообщение
-			storedMsg := &telebot.StoredMessage{
-				MessageID: *inlineMessageID,
-			}
-			_, editErr = b.bot.Edit(storedMsg, msg, markup)
-			ok = CheckIsUpdatingSuccess(editErr)
-			if !ok {
-				log.Printf("❌ [UpdateWorker] Ошибка обновления inline-сообщения %s (poll=%d): %v",
-					*inlineMessageID, pollID, editErr)
-			}
-		} else if chatID != nil && messageID != nil {
-			// Обычное сообщение в чате
-			storedMsg := &telebot.StoredMessage{
-				MessageID: strconv.FormatInt(*messageID, 10),
-				ChatID:    *chatID,
-			}
-			_, editErr = b.bot.Edit(storedMsg, msg, markup)
-			ok = CheckIsUpdatingSuccess(editErr)
-			if !ok {
-				log.Printf("❌ [UpdateWorker] Ошибка обновления сообщения (chat=%d, msg=%d, poll=%d): %v",
-					*chatID, *messageID, pollID, editErr)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	sem := make(chan struct{}, b.rateLimit.EditParallelism)
+	for _, key := range chatKeys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string, group []PollChat) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			done, errs, left, delay := b.editChatMessages(ctx, pollID, key, group, msg, markup, newHash)
+
+			mu.Lock()
+			defer mu.Unlock()
+			updated += done
+			failed += errs
+			if left > 0 {
+				// Отложены последние left сообщений чата
+				deferred = append(deferred, group[len(group)-left:]...)
+				if retryIn == 0 || delay < retryIn {
+					retryIn = delay
+				}
 			}
-		} else {
+		}(key, byChat[key])
+	}
+	wg.Wait()
+	return updated, failed, deferred, retryIn
+}
+
+// editChatMessages по очереди правит сообщения голосования в одном чате.
+// Возвращает число обновленных сообщений, число неудачных правок, число отложенных
+// и задержку, через которую отложенные нужно повторить.
+// newHash == 0 — хеш не сохраняется (публикации удаленного голосования уже нет в хранилище).
+func (b *Bot) editChatMessages(ctx context.Context, pollID int64, chatKey string, group []PollChat, msg string, markup *telebot.ReplyMarkup, newHash int64) (updated, failed, left int, retryIn time.Duration) {
+	log := b.log.With("component", "UpdateWorker", "poll_id", pollID)
+	for i, chat := range group {
+		// Короткую паузу общего бюджета пережидаем на месте, длинную — через очередь
+		delay := b.editBudget.reserve(chatKey)
+		for delay > 0 && delay <= time.Duration(b.rateLimit.EditMaxWait) {
+			time.Sleep(delay)
+			delay = b.editBudget.reserve(chatKey)
+		}
+		if delay > 0 {
+			return updated, failed, len(group) - i, delay
+		}
+
+		editErr := b.editPollChat(chat, msg, markup)
+
+		var flood telebot.FloodError
+		if errors.As(editErr, &flood) {
+			retryAfter := time.Duration(flood.RetryAfter) * time.Second
+			b.editBudget.penalize(chatKey, retryAfter)
+			log.Warn("telegram flood limit on edits", "chat", chatKey, "retry_after", retryAfter)
+			return updated, failed, len(group) - i, retryAfter
+		}
+
+		if !CheckIsUpdatingSuccess(editErr) {
+			log.Error("failed to edit poll message", "chat", chatKey, "poll_chat_id", chat.ID, "error", editErr)
+			failed++
 			continue
 		}
 
 		// После успешного обновления сохраняем новый хеш
-		if ok {
-			_, err = b.db.Exec(ctx,
-				`UPDATE voting.poll_chats SET message_hash = $1 WHERE id = $2`,
-				newHash, rowID)
-			if err != nil {
-				log.Printf("❌ [UpdateWorker] Ошибка сохранения хеша для poll_chats id=%d: %v", rowID, err)
+		if newHash != 0 {
+			if err := b.store.SetPollChatHash(ctx, chat.ID, newHash); err != nil {
+				log.Error("failed to save message hash", "poll_chat_id", chat.ID, "error", err)
 			}
-			updated++
 		}
+		updated++
 	}
+	return updated, failed, 0, 0
+}
 
-	log.Printf("✅ [UpdateWorker] Голосование %d: обновлено %d, пропущено %d (хеш не изменился)", pollID, updated, skipped)
+// editPollChat правит одно опубликованное сообщение голосования
+func (b *Bot) editPollChat(chat PollChat, msg string, markup *telebot.ReplyMarkup) error {
+	storedMsg := &telebot.StoredMessage{MessageID: chat.InlineMessageID}
+	if chat.InlineMessageID == "" {
+		storedMsg = &telebot.StoredMessage{
+			MessageID: strconv.FormatInt(chat.MessageID, 10),
+			ChatID:    chat.ChatID,
+		}
+	}
+	_, err := b.bot.Edit(storedMsg, msg, markup)
+	return err
+}
+
+// pollChatKey ключ бюджета правок для публикации: чат или inline-сообщение
+// ("" — публикация без адреса, править нечего)
+func pollChatKey(chat PollChat) string {
+	switch {
+	case chat.InlineMessageID != "":
+		return "inline:" + chat.InlineMessageID
+	case chat.ChatID != 0 && chat.MessageID != 0:
+		return "chat:" + strconv.FormatInt(chat.ChatID, 10)
+	default:
+		return ""
+	}
 }
 
 func CheckIsUpdatingSuccess(editErr error) bool {
@@ -929,6 +1349,18 @@ func CheckIsUpdatingSuccess(editErr error) bool {
 	return false
 }
 
+// pollMessageHash хеширует текст сообщения вместе с клавиатурой:
+// переименование варианта или закрытие голосования меняют кнопки, даже если текст тот же
+func pollMessageHash(msg string, markup *telebot.ReplyMarkup) int64 {
+	keyboard := ""
+	if markup != nil {
+		if data, err := json.Marshal(markup.InlineKeyboard); err == nil {
+			keyboard = string(data)
+		}
+	}
+	return int64(FastHash(msg + "\x00" + keyboard))
+}
+
 // FastHash быстрая хеш-функция для строк
 func FastHash(s string) uint64 {
 	var h uint64 = 146527 // random prime-ish
@@ -0,0 +1,284 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telebot.v4"
+)
+
+// Типы голосований
+const (
+	VoteTypePlurality = "plurality" // Обычное голосование: побеждает вариант с наибольшим числом голосов
+	VoteTypeRanked    = "ranked"    // Рейтинговое голосование: варианты ранжируются, подсчет по IRV
+)
+
+// rankStartPrefix префикс deep-link параметра /start для перехода к ранжированию
+const rankStartPrefix = "rank_"
+
+// handleRankButton обрабатывает кнопку "Ранжировать варианты" в опубликованном голосовании.
+// Ранжирование проходит в личном чате с ботом, поэтому в ответ открываем deep-link.
+func (b *Bot) handleRankButton(c telebot.Context) error {
+	data := strings.TrimPrefix(c.Data(), "\frank|")
+	pollID, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка данных голосования"})
+	}
+
+	return c.Respond(&telebot.CallbackResponse{
+		URL: fmt.Sprintf("https://t.me/%s?start=%s%d", b.bot.Me.Username, rankStartPrefix, pollID),
+	})
+}
+
+// handleRankStart начинает ранжирование вариантов в личном чате (/start rank_<ID>)
+func (b *Bot) handleRankStart(c telebot.Context, payload string) error {
+	pollID, err := strconv.ParseInt(strings.TrimPrefix(payload, rankStartPrefix), 10, 64)
+	if err != nil {
+		return c.Send("❌ Некорректная ссылка на голосование")
+	}
+	if b.isBanned(c.Sender().ID) {
+		return c.Send(bannedMessage)
+	}
+
+	ctx := context.Background()
+	poll, err := b.store.GetPoll(ctx, pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return c.Send("❌ Голосование не найдено")
+	}
+	if poll.VoteType != VoteTypeRanked {
+		return c.Send("❌ Это голосование не рейтинговое — голосуйте кнопками под сообщением.")
+	}
+	if poll.IsClosed() {
+		return c.Send("🔒 Голосование завершено")
+	}
+
+	userID := c.Sender().ID
+	b.dialog.ResetContext(userID)
+	b.dialog.Update(userID, func(ctx *DialogContext) {
+		ctx.State = StateRankPoll
+		ctx.Data.RankPollID = pollID
+	})
+
+	text, markup := formatRankingStep(poll, nil)
+	return c.Send(text, markup)
+}
+
+// handleRankPickCallback добавляет вариант в конец ранжирования пользователя
+func (b *Bot) handleRankPickCallback(c telebot.Context) error {
+	poll, order, ok := b.rankingContext(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного ранжирования"})
+	}
+
+	optionID, err := strconv.ParseInt(strings.TrimPrefix(c.Data(), "\frank_pick|"), 10, 64)
+	if err != nil || !pollHasOption(poll, optionID) {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка данных варианта"})
+	}
+	for _, ranked := range order {
+		if ranked == optionID {
+			return c.Respond(&telebot.CallbackResponse{Text: "Этот вариант уже в списке"})
+		}
+	}
+
+	order = append(order, optionID)
+	b.dialog.UpdateData(c.Sender().ID, func(data *DialogData) {
+		data.RankOrder = order
+	})
+
+	c.Respond(&telebot.CallbackResponse{})
+	text, markup := formatRankingStep(poll, order)
+	return c.Edit(text, markup)
+}
+
+// handleRankResetCallback очищает ранжирование и начинает выбор заново
+func (b *Bot) handleRankResetCallback(c telebot.Context) error {
+	poll, _, ok := b.rankingContext(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного ранжирования"})
+	}
+
+	b.dialog.UpdateData(c.Sender().ID, func(data *DialogData) {
+		data.RankOrder = nil
+	})
+
+	c.Respond(&telebot.CallbackResponse{Text: "↩️ Ранжирование сброшено"})
+	text, markup := formatRankingStep(poll, nil)
+	return c.Edit(text, markup)
+}
+
+// handleRankSubmitCallback сохраняет бюллетень пользователя
+func (b *Bot) handleRankSubmitCallback(c telebot.Context) error {
+	poll, order, ok := b.rankingContext(c)
+	if !ok {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Нет активного ранжирования"})
+	}
+	if len(order) == 0 {
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Выберите хотя бы один вариант", ShowAlert: true})
+	}
+
+	ctx := context.Background()
+	user := c.Sender()
+	if b.isBanned(user.ID) {
+		b.metrics.countVote(voteOutcomeBanned)
+		b.dialog.ResetContext(user.ID)
+		return c.Respond(&telebot.CallbackResponse{Text: bannedMessage, ShowAlert: true})
+	}
+	if err := b.store.SaveBallot(ctx, poll.ID, voterFromUser(user), order); err != nil {
+		if errors.Is(err, errPollClosed) {
+			b.metrics.countVote(voteOutcomeClosed)
+			b.dialog.ResetContext(user.ID)
+			return c.Respond(&telebot.CallbackResponse{Text: "🔒 Голосование завершено", ShowAlert: true})
+		}
+		b.metrics.countVote(voteOutcomeError)
+		b.log.Error("failed to save ballot", "poll_id", poll.ID, "user_id", user.ID, "error", err)
+		return c.Respond(&telebot.CallbackResponse{Text: "❌ Ошибка сохранения бюллетеня"})
+	}
+
+	b.metrics.countVote(voteActionBallot)
+	b.log.Info("ballot submitted", "poll_id", poll.ID, "user_id", user.ID, "ranking", order)
+
+	b.dialog.ResetContext(user.ID)
+	b.updateQueue.Schedule(poll.ID)
+
+	c.Respond(&telebot.CallbackResponse{Text: "✅ Бюллетень сохранен!"})
+	return c.Edit(fmt.Sprintf("✅ Ваш бюллетень в голосовании «%s» сохранен:\n\n%s\n"+
+		"Чтобы изменить порядок, снова нажмите «Ранжировать варианты» под голосованием.",
+		poll.Title, formatRanking(poll, order)))
+}
+
+// rankingContext возвращает голосование и текущее ранжирование из контекста диалога
+func (b *Bot) rankingContext(c telebot.Context) (*PollData, []int64, bool) {
+	dialogCtx := b.dialog.GetContext(c.Sender().ID)
+	if dialogCtx.State != StateRankPoll || dialogCtx.Data.RankPollID == 0 {
+		return nil, nil, false
+	}
+	pollID := dialogCtx.Data.RankPollID
+	order := dialogCtx.Data.RankOrder
+
+	poll, err := b.store.GetPoll(context.Background(), pollID)
+	if err != nil {
+		b.log.Error("failed to get poll", "poll_id", pollID, "error", err)
+		return nil, nil, false
+	}
+	return poll, order, true
+}
+
+// formatRankingStep форматирует текущий шаг ранжирования и клавиатуру с оставшимися вариантами
+func formatRankingStep(poll *PollData, order []int64) (string, *telebot.ReplyMarkup) {
+	text := fmt.Sprintf("🔢 %s\n\n", poll.Title)
+	if len(order) == 0 {
+		text += "Нажимайте на варианты в порядке предпочтения: первым — самый желанный.\n"
+	} else {
+		text += "Ваш порядок:\n" + formatRanking(poll, order)
+		text += "\nВыберите следующий вариант или отправьте бюллетень. Можно ранжировать не все варианты.\n"
+	}
+
+	ranked := make(map[int64]bool, len(order))
+	for _, optionID := range order {
+		ranked[optionID] = true
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	rows := make([]telebot.Row, 0)
+	for _, opt := range poll.Options {
+		if ranked[opt.ID] {
+			continue
+		}
+		btn := markup.Data(opt.Text, "rank_pick", strconv.FormatInt(opt.ID, 10))
+		rows = append(rows, markup.Row(btn))
+	}
+	if len(order) > 0 {
+		btnReset := markup.Data("↩️ Сбросить", "rank_reset")
+		btnSubmit := markup.Data("✅ Отправить", "rank_submit")
+		rows = append(rows, markup.Row(btnReset, btnSubmit))
+	}
+	markup.Inline(rows...)
+
+	return text, markup
+}
+
+// formatRanking форматирует ранжирование пользователя нумерованным списком
+func formatRanking(poll *PollData, order []int64) string {
+	text := ""
+	for i, optionID := range order {
+		text += fmt.Sprintf("%d. %s\n", i+1, pollOptionText(poll, optionID))
+	}
+	return text
+}
+
+// pollHasOption проверяет, что вариант принадлежит голосованию
+func pollHasOption(poll *PollData, optionID int64) bool {
+	for _, opt := range poll.Options {
+		if opt.ID == optionID {
+			return true
+		}
+	}
+	return false
+}
+
+// pollOptionText возвращает текст варианта по ID
+func pollOptionText(poll *PollData, optionID int64) string {
+	for _, opt := range poll.Options {
+		if opt.ID == optionID {
+			return opt.Text
+		}
+	}
+	return "?"
+}
+
+// formatRankedResults форматирует раунды подсчета рейтингового голосования
+func formatRankedResults(poll *PollData) string {
+	optionIDs := make([]int64, 0, len(poll.Options))
+	for _, opt := range poll.Options {
+		optionIDs = append(optionIDs, opt.ID)
+	}
+	result := TallyIRV(optionIDs, poll.Ballots)
+
+	msg := ""
+	for i, round := range result.Rounds {
+		msg += fmt.Sprintf("\nРаунд %d:\n", i+1)
+		eliminated := make(map[int64]bool, len(round.Eliminated))
+		for _, optionID := range round.Eliminated {
+			eliminated[optionID] = true
+		}
+		for _, opt := range poll.Options {
+			votes, ok := round.Counts[opt.ID]
+			if !ok {
+				continue
+			}
+			line := fmt.Sprintf("%s – %d", opt.Text, votes)
+			if eliminated[opt.ID] {
+				line += " ❌"
+			}
+			msg += line + "\n"
+		}
+		if round.Exhausted > 0 {
+			msg += fmt.Sprintf("🗑 Исчерпано бюллетеней: %d\n", round.Exhausted)
+		}
+	}
+
+	winners := make([]PollOption, 0, len(result.Winners))
+	for _, optionID := range result.Winners {
+		for _, opt := range poll.Options {
+			if opt.ID == optionID {
+				winners = append(winners, opt)
+			}
+		}
+	}
+
+	if poll.IsClosed() {
+		msg += "\n" + formatPollOutcome(winners)
+	} else if len(winners) == 1 {
+		msg += fmt.Sprintf("\n📈 Лидирует: %s", winners[0].Text)
+	} else if len(winners) > 1 {
+		msg += "\n" + formatPollOutcome(winners)
+	} else {
+		msg += "\nПока никто не проголосовал"
+	}
+
+	return msg
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"wubrg-voting-bot/config"
+)
+
+// voteLimiterIdleTTL время, после которого неиспользуемые корзины удаляются
+const voteLimiterIdleTTL = 10 * time.Minute
+
+// tokenBucket корзина токенов: пополняется со скоростью rate до burst,
+// каждое разрешенное нажатие забирает один токен
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill пополняет корзину на время, прошедшее с прошлого обращения
+func (tb *tokenBucket) refill(now time.Time, rate, burst float64) {
+	if elapsed := now.Sub(tb.last).Seconds(); elapsed > 0 {
+		tb.tokens = min(burst, tb.tokens+elapsed*rate)
+	}
+	tb.last = now
+}
+
+// VoteLimiter ограничивает частоту нажатий на кнопки вариантов
+// одновременно по пользователю и по голосованию.
+// Пользователь может быстро нажать несколько кнопок подряд (VoteUserBurst),
+// дальше — не чаще VoteUserRate нажатий в секунду. Общий лимит голосования
+// защищает от множества пользователей, одновременно «кликающих» одно сообщение.
+type VoteLimiter struct {
+	mu        sync.Mutex
+	cfg       config.RateLimit
+	users     map[int64]*tokenBucket
+	polls     map[int64]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time // подменяется в тестах
+}
+
+// NewVoteLimiter создает ограничитель с полными корзинами по проверенным настройкам cfg
+func NewVoteLimiter(cfg config.RateLimit) *VoteLimiter {
+	return &VoteLimiter{
+		cfg:       cfg,
+		users:     make(map[int64]*tokenBucket),
+		polls:     make(map[int64]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow сообщает, можно ли обработать нажатие пользователя в голосовании.
+// Токен списывается из обеих корзин только если хватает в каждой,
+// чтобы отклоненное по лимиту голосования нажатие не тратило лимит пользователя.
+func (l *VoteLimiter) Allow(userID, pollID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	user := l.bucket(l.users, userID, now, l.cfg.VoteUserRate, float64(l.cfg.VoteUserBurst))
+	poll := l.bucket(l.polls, pollID, now, l.cfg.VotePollRate, float64(l.cfg.VotePollBurst))
+	if user.tokens < 1 || poll.tokens < 1 {
+		return false
+	}
+	user.tokens--
+	poll.tokens--
+	return true
+}
+
+// bucket возвращает пополненную корзину по ключу, создавая полную при первом обращении
+// (вызывается под mu)
+func (l *VoteLimiter) bucket(buckets map[int64]*tokenBucket, key int64, now time.Time, rate, burst float64) *tokenBucket {
+	tb, ok := buckets[key]
+	if !ok {
+		tb = &tokenBucket{tokens: burst, last: now}
+		buckets[key] = tb
+		return tb
+	}
+	tb.refill(now, rate, burst)
+	return tb
+}
+
+// sweep удаляет корзины, к которым давно не обращались: за voteLimiterIdleTTL
+// они все равно пополнились бы до полной (вызывается под mu)
+func (l *VoteLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < voteLimiterIdleTTL {
+		return
+	}
+	l.lastSweep = now
+	for _, buckets := range []map[int64]*tokenBucket{l.users, l.polls} {
+		for key, tb := range buckets {
+			if now.Sub(tb.last) >= voteLimiterIdleTTL {
+				delete(buckets, key)
+			}
+		}
+	}
+}
+
+// editBudget общий и по-чатовый лимит правок сообщений с учетом retry_after от Telegram.
+// Telegram ограничивает частоту запросов бота в целом (~30 в секунду) и в одном чате
+// (в группах ~20 в минуту); при превышении отвечает 429 с retry_after.
+type editBudget struct {
+	mu        sync.Mutex
+	cfg       config.RateLimit
+	global    tokenBucket
+	chats     map[string]*tokenBucket
+	paused    time.Time // время окончания паузы после 429 (общей для всех чатов)
+	lastSweep time.Time
+	now       func() time.Time // подменяется в тестах
+}
+
+// newEditBudget создает бюджет с полными корзинами по проверенным настройкам cfg
+func newEditBudget(cfg config.RateLimit) *editBudget {
+	now := time.Now()
+	return &editBudget{
+		cfg:       cfg,
+		global:    tokenBucket{tokens: float64(cfg.EditGlobalBurst), last: now},
+		chats:     make(map[string]*tokenBucket),
+		lastSweep: now,
+		now:       time.Now,
+	}
+}
+
+// reserve списывает правку из бюджета чата и общего бюджета.
+// Возвращает 0, если править можно сейчас, иначе — через сколько стоит повторить
+// (бюджет при этом не списывается).
+func (e *editBudget) reserve(chatKey string) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if e.paused.After(now) {
+		return e.paused.Sub(now)
+	}
+
+	chatRate, chatBurst := e.cfg.EditChatPerMinute/60, float64(e.cfg.EditChatBurst)
+	chat, ok := e.chats[chatKey]
+	if !ok {
+		chat = &tokenBucket{tokens: chatBurst, last: now}
+		e.chats[chatKey] = chat
+	}
+	chat.refill(now, chatRate, chatBurst)
+	if chat.tokens < 1 {
+		return tokenDelay(chat.tokens, chatRate)
+	}
+	e.global.refill(now, e.cfg.EditGlobalRate, float64(e.cfg.EditGlobalBurst))
+	if e.global.tokens < 1 {
+		return tokenDelay(e.global.tokens, e.cfg.EditGlobalRate)
+	}
+
+	chat.tokens--
+	e.global.tokens--
+	e.sweep(now)
+	return 0
+}
+
+// penalize приостанавливает все правки на retry_after, который вернул Telegram.
+// Флуд-лимит считается на весь бот, поэтому пауза не ограничивается чатом с 429:
+// иначе остальные чаты продолжили бы расходовать тот же лимит. После паузы
+// общий бюджет начинается с нуля, поэтому правки возобновляются постепенно.
+func (e *editBudget) penalize(chatKey string, retryAfter time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if until := e.now().Add(retryAfter); until.After(e.paused) {
+		e.paused = until
+	}
+	// Общая корзина начинает пополняться только с конца паузы
+	e.global = tokenBucket{last: e.paused}
+	if chat, ok := e.chats[chatKey]; ok {
+		chat.tokens = 0
+	}
+}
+
+// sweep удаляет корзины чатов, которые давно пополнились до полной (вызывается под mu)
+func (e *editBudget) sweep(now time.Time) {
+	if now.Sub(e.lastSweep) < voteLimiterIdleTTL {
+		return
+	}
+	e.lastSweep = now
+	for key, chat := range e.chats {
+		if now.Sub(chat.last) >= voteLimiterIdleTTL {
+			delete(e.chats, key)
+		}
+	}
+}
+
+// tokenDelay время, за которое корзина со скоростью rate накопит один токен
+func tokenDelay(tokens, rate float64) time.Duration {
+	return time.Duration((1 - tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL время жизни незавершенного диалога без активности
+const DefaultSessionTTL = 24 * time.Hour
+
+// sessionCleanupInterval период удаления просроченных сессий
+const sessionCleanupInterval = 10 * time.Minute
+
+// SessionStore хранилище контекстов диалогов пользователей.
+// Каждое сохранение продлевает время жизни сессии на TTL хранилища.
+type SessionStore interface {
+	// Load возвращает контекст пользователя; exists == false, если сессии нет или она истекла
+	Load(ctx context.Context, userID int64) (dialog *DialogContext, exists bool, err error)
+	// Save сохраняет контекст пользователя
+	Save(ctx context.Context, userID int64, dialog *DialogContext) error
+	// Delete удаляет контекст пользователя
+	Delete(ctx context.Context, userID int64) error
+	// Count возвращает количество действующих сессий
+	Count(ctx context.Context) (int, error)
+	// DeleteExpired удаляет просроченные сессии и возвращает их количество
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// memorySession сериализованный контекст и срок его жизни
+type memorySession struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// MemorySessionStore хранит сессии в памяти процесса (теряются при перезапуске).
+// Контексты хранятся в JSON, как и в PostgresSessionStore, чтобы вызывающий код
+// не мог изменить сохраненную сессию через возвращенный указатель.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	ttl      time.Duration
+	sessions map[int64]memorySession // userID -> сессия
+}
+
+// NewMemorySessionStore создает хранилище сессий в памяти
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		ttl:      ttl,
+		sessions: make(map[int64]memorySession),
+	}
+}
+
+// Load возвращает контекст пользователя
+func (s *MemorySessionStore) Load(_ context.Context, userID int64) (*DialogContext, bool, error) {
+	s.mu.RLock()
+	session, exists := s.sessions[userID]
+	s.mu.RUnlock()
+
+	if !exists || !session.expiresAt.After(time.Now()) {
+		return nil, false, nil
+	}
+
+	var dialog DialogContext
+	if err := json.Unmarshal(session.data, &dialog); err != nil {
+		return nil, false, err
+	}
+	return &dialog, true, nil
+}
+
+// Save сохраняет контекст пользователя
+func (s *MemorySessionStore) Save(_ context.Context, userID int64, dialog *DialogContext) error {
+	data, err := json.Marshal(dialog)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[userID] = memorySession{
+		data:      data,
+		expiresAt: time.Now().Add(s.ttl),
+	}
+	return nil
+}
+
+// Delete удаляет контекст пользователя
+func (s *MemorySessionStore) Delete(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+	return nil
+}
+
+// Count возвращает количество действующих сессий
+func (s *MemorySessionStore) Count(_ context.Context) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range s.sessions {
+		if session.expiresAt.After(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteExpired удаляет просроченные сессии
+func (s *MemorySessionStore) DeleteExpired(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for userID, session := range s.sessions {
+		if !session.expiresAt.After(now) {
+			delete(s.sessions, userID)
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresSessionStore хранит сессии в таблице voting.dialog_sessions.
+// Незавершенные диалоги переживают перезапуск и доступны всем экземплярам бота.
+type PostgresSessionStore struct {
+	db  *pgxpool.Pool
+	ttl time.Duration
+}
+
+// NewPostgresSessionStore создает хранилище сессий в PostgreSQL
+func NewPostgresSessionStore(db *pgxpool.Pool, ttl time.Duration) *PostgresSessionStore {
+	return &PostgresSessionStore{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// Load возвращает контекст пользователя
+func (s *PostgresSessionStore) Load(ctx context.Context, userID int64) (*DialogContext, bool, error) {
+	var state string
+	var data []byte
+	err := s.db.QueryRow(ctx,
+		`SELECT state, data FROM voting.dialog_sessions
+		 WHERE user_telegram_id = $1 AND expires_at > NOW()`,
+		userID).Scan(&state, &data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка загрузки сессии: %w", err)
+	}
+
+	dialog := &DialogContext{State: State(state)}
+	if err := json.Unmarshal(data, &dialog.Data); err != nil {
+		return nil, false, fmt.Errorf("ошибка разбора данных сессии: %w", err)
+	}
+	return dialog, true, nil
+}
+
+// Save сохраняет контекст пользователя и продлевает срок жизни сессии
+func (s *PostgresSessionStore) Save(ctx context.Context, userID int64, dialog *DialogContext) error {
+	data, err := json.Marshal(dialog.Data)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации данных сессии: %w", err)
+	}
+
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO voting.dialog_sessions (user_telegram_id, state, data, updated_at, expires_at)
+		 VALUES ($1, $2, $3, NOW(), NOW() + $4::interval)
+		 ON CONFLICT (user_telegram_id)
+		 DO UPDATE SET state = EXCLUDED.state, data = EXCLUDED.data,
+		               updated_at = EXCLUDED.updated_at, expires_at = EXCLUDED.expires_at`,
+		userID, string(dialog.State), data, s.ttl)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения сессии: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет контекст пользователя
+func (s *PostgresSessionStore) Delete(ctx context.Context, userID int64) error {
+	_, err := s.db.Exec(ctx,
+		`DELETE FROM voting.dialog_sessions WHERE user_telegram_id = $1`,
+		userID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления сессии: %w", err)
+	}
+	return nil
+}
+
+// Count возвращает количество действующих сессий
+func (s *PostgresSessionStore) Count(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM voting.dialog_sessions WHERE expires_at > NOW()`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчета сессий: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteExpired удаляет просроченные сессии
+func (s *PostgresSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM voting.dialog_sessions WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления просроченных сессий: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
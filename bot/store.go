@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Ошибки хранилища, на которые реагируют обработчики
+var (
+	// errPollNotFound возвращается, если голосования с таким ID нет
+	errPollNotFound = errors.New("голосование не найдено")
+	// errPollClosed возвращается при попытке проголосовать в завершенном голосовании
+	errPollClosed = errors.New("голосование завершено")
+	// errPollRanked возвращается при попытке проголосовать кнопкой в рейтинговом голосовании
+	errPollRanked = errors.New("голосование рейтинговое")
+	// errPollNotRanked возвращается при попытке отправить бюллетень в обычное голосование
+	errPollNotRanked = errors.New("голосование не рейтинговое")
+	// errOptionNotFound возвращается, если варианта с таким ID нет в голосовании
+	errOptionNotFound = errors.New("вариант не найден")
+	// errOptionHasVotes возвращается при попытке удалить вариант, за который уже голосовали
+	errOptionHasVotes = errors.New("за вариант уже голосовали")
+	// errTooFewOptions возвращается, если после удаления в голосовании осталось бы меньше двух вариантов
+	errTooFewOptions = errors.New("в голосовании должно остаться минимум 2 варианта")
+	// errUserNotFound возвращается, если пользователя с таким username нет среди авторов и участников
+	errUserNotFound = errors.New("пользователь не найден")
+	// errUserNotBanned возвращается при снятии блокировки с незаблокированного пользователя
+	errUserNotBanned = errors.New("пользователь не заблокирован")
+)
+
+// Store хранилище голосований, вариантов, голосов, бюллетеней, публикаций и лога нажатий.
+//
+// Обработчики бота работают только через этот интерфейс: PostgresStore используется
+// в работе, MemoryStore — в тестах. Обе реализации обязаны вести себя одинаково,
+// включая upsert с ON CONFLICT DO NOTHING и каскадное удаление голосования.
+type Store interface {
+	// Ping проверяет доступность хранилища
+	Ping(ctx context.Context) error
+
+	// CreatePoll сохраняет голосование вместе с вариантами и возвращает его ID
+	CreatePoll(ctx context.Context, creatorID int64, creatorUsername string, draft PollDraft) (int64, error)
+	// GetPoll возвращает голосование с вариантами, голосами и бюллетенями (errPollNotFound, если его нет).
+	// Завершенные голосования тоже возвращаются, чтобы их можно было перерисовать.
+	GetPoll(ctx context.Context, pollID int64) (*PollData, error)
+	// ListActivePolls возвращает последние активные голосования пользователя
+	ListActivePolls(ctx context.Context, creatorID int64, limit int) ([]PollSummary, error)
+	// SearchActivePolls ищет активные голосования пользователя по названию и описанию
+	// (без учета регистра, query == "" — все голосования), новые первыми
+	SearchActivePolls(ctx context.Context, creatorID int64, query string, limit, offset int) ([]*PollData, error)
+	// SetPollActive завершает или возобновляет голосование.
+	// При возобновлении истекший срок окончания сбрасывается.
+	SetPollActive(ctx context.Context, pollID int64, active bool) error
+	// UpdatePollText меняет заголовок и описание голосования ("" — без описания)
+	UpdatePollText(ctx context.Context, pollID int64, title, description string) error
+	// AddOption добавляет вариант в голосование и возвращает его ID
+	AddOption(ctx context.Context, pollID int64, text string) (int64, error)
+	// RenameOption меняет текст варианта (эмодзи пересчитывается по новому префиксу)
+	RenameOption(ctx context.Context, pollID, optionID int64, text string) error
+	// DeleteOption удаляет вариант без голосов и бюллетеней
+	// (errOptionHasVotes / errTooFewOptions, если удалять нельзя)
+	DeleteOption(ctx context.Context, pollID, optionID int64) error
+	// CloseExpiredPolls завершает голосования с истекшим сроком и возвращает их ID
+	CloseExpiredPolls(ctx context.Context) ([]int64, error)
+	// DeletePoll удаляет голосование вместе с вариантами, голосами, бюллетенями и публикациями.
+	// Лог нажатий (vote_log) не удаляется.
+	DeletePoll(ctx context.Context, pollID int64) error
+
+	// CastVote записывает нажатие в vote_log и сохраняет голос по правилам голосования
+	// (errPollClosed / errPollRanked, если голосовать кнопкой нельзя)
+	CastVote(ctx context.Context, pollID, optionID int64, voter Vote) (VoteResult, error)
+	// LogThrottledVote записывает в vote_log нажатие, отклоненное ограничением частоты
+	LogThrottledVote(ctx context.Context, pollID, optionID, userID int64) error
+	// SaveBallot сохраняет (или заменяет) бюллетень рейтингового голосования
+	SaveBallot(ctx context.Context, pollID int64, voter Vote, order []int64) error
+	// ListVoteRecords возвращает текущие голоса по одному на строку в порядке голосования.
+	// Для рейтингового голосования строки берутся из бюллетеней (Rank > 0).
+	ListVoteRecords(ctx context.Context, pollID int64) ([]VoteRecord, error)
+	// ListVoteLog возвращает историю нажатий голосования из vote_log в хронологическом порядке
+	ListVoteLog(ctx context.Context, pollID int64) ([]VoteLogEntry, error)
+
+	// AddPollChat запоминает публикацию голосования в чате (повторная публикация игнорируется)
+	AddPollChat(ctx context.Context, pollID, chatID, messageID int64) error
+	// AddInlinePollChat запоминает inline-публикацию голосования (повторная игнорируется)
+	AddInlinePollChat(ctx context.Context, pollID int64, inlineMessageID string) error
+	// ListPollChats возвращает все публикации голосования
+	ListPollChats(ctx context.Context, pollID int64) ([]PollChat, error)
+	// SetPollChatHash сохраняет хеш последнего отрисованного текста публикации
+	SetPollChatHash(ctx context.Context, pollChatID, hash int64) error
+
+	// GetStats возвращает общие счетчики по всем голосованиям (/admin stats)
+	GetStats(ctx context.Context) (Stats, error)
+	// BanUser блокирует пользователя (повторная блокировка игнорируется)
+	BanUser(ctx context.Context, userID, adminID int64) error
+	// UnbanUser снимает блокировку (errUserNotBanned, если пользователь не заблокирован)
+	UnbanUser(ctx context.Context, userID int64) error
+	// ListBannedUsers возвращает Telegram ID всех заблокированных пользователей
+	ListBannedUsers(ctx context.Context) ([]int64, error)
+	// FindUserID ищет Telegram ID по username (без @, без учета регистра) среди авторов
+	// голосований и участников; при нескольких совпадениях берется самое свежее (errUserNotFound, если нет)
+	FindUserID(ctx context.Context, username string) (int64, error)
+}
+
+// Stats общие счетчики хранилища для /admin stats
+type Stats struct {
+	Polls        int // Все голосования
+	ActivePolls  int // Открытые голосования с неистекшим сроком
+	Creators     int // Уникальные авторы голосований
+	Votes        int // Голоса обычных голосований (строки votes)
+	Ballots      int // Бюллетени рейтинговых голосований
+	Voters       int // Уникальные участники (голоса и бюллетени)
+	Publications int // Опубликованные сообщения (в чатах и inline)
+	VoteLog      int // Записи vote_log
+	BannedUsers  int // Заблокированные пользователи
+}
+
+// PollSummary краткие данные голосования для списка /listpolls
+type PollSummary struct {
+	ID        int64
+	Title     string
+	CreatedAt time.Time
+}
+
+// PollChat публикация голосования: сообщение в чате или inline-сообщение
+type PollChat struct {
+	ID              int64
+	ChatID          int64  // 0 для inline-публикации
+	MessageID       int64  // 0 для inline-публикации
+	InlineMessageID string // "" для публикации в чате
+	MessageHash     *int64 // nil — сообщение еще не перерисовывалось
+}
+
+// VoteRecord голос пользователя за вариант (или строка бюллетеня рейтингового голосования)
+type VoteRecord struct {
+	Voter    Vote
+	OptionID int64
+	Rank     int // Место варианта в бюллетене (0 — обычный голос)
+	VotedAt  time.Time
+}
+
+// VoteLogEntry запись vote_log: одно нажатие на кнопку или отправка бюллетеня
+type VoteLogEntry struct {
+	UserID    int64
+	OptionID  int64
+	Action    string // "" — запись сделана до появления vote_log.action
+	ClickedAt time.Time
+}
+
+// VoteOutcome итог нажатия на кнопку варианта
+type VoteOutcome int
+
+const (
+	VoteRecorded     VoteOutcome = iota // Голос учтен (один вариант, пользователь еще не голосовал)
+	VoteChanged                         // Голос перенесен на другой вариант (один вариант)
+	VoteWithdrawn                       // Голос отозван повторным нажатием (один вариант)
+	VoteSelected                        // Вариант выбран (несколько вариантов)
+	VoteDeselected                      // Выбор снят (несколько вариантов)
+	VoteLimitReached                    // Выбор отклонен: достигнут лимит вариантов
+)
+
+// Значения voting.vote_log.action
+const (
+	voteActionRecorded     = "recorded"
+	voteActionChanged      = "changed"
+	voteActionWithdrawn    = "withdrawn"
+	voteActionSelected     = "selected"
+	voteActionDeselected   = "deselected"
+	voteActionLimitReached = "limit_reached"
+	voteActionBallot       = "ballot"    // Отправка бюллетеня рейтингового голосования
+	voteActionThrottled    = "throttled" // Нажатие отклонено ограничением частоты, голос не менялся
+)
+
+// logAction возвращает значение vote_log.action для итога нажатия
+func (o VoteOutcome) logAction() string {
+	switch o {
+	case VoteChanged:
+		return voteActionChanged
+	case VoteWithdrawn:
+		return voteActionWithdrawn
+	case VoteSelected:
+		return voteActionSelected
+	case VoteDeselected:
+		return voteActionDeselected
+	case VoteLimitReached:
+		return voteActionLimitReached
+	default:
+		return voteActionRecorded
+	}
+}
+
+// VoteResult результат CastVote
+type VoteResult struct {
+	Outcome       VoteOutcome
+	MaxSelections int // Лимит выбранных вариантов (для VoteLimitReached)
+}
@@ -0,0 +1,778 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryPoll строка таблицы voting.polls
+type memoryPoll struct {
+	ID              int64
+	Title           string
+	Description     string
+	CreatorID       int64
+	CreatorUsername string
+	IsActive        bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ExpiresAt       *time.Time
+	AllowMultiple   bool
+	MaxSelections   int
+	IsAnonymous     bool
+	VoteType        string
+}
+
+// memoryOption строка таблицы voting.poll_options
+type memoryOption struct {
+	ID     int64
+	PollID int64
+	Text   string
+	Emoji  string
+}
+
+// memoryVote строка таблицы voting.votes
+type memoryVote struct {
+	PollID   int64
+	OptionID int64
+	Voter    Vote
+	VotedAt  time.Time
+}
+
+// memoryBallotRank строка таблицы voting.ballots
+type memoryBallotRank struct {
+	PollID      int64
+	OptionID    int64
+	Rank        int
+	Voter       Vote
+	SubmittedAt time.Time
+}
+
+// memoryPollChat строка таблицы voting.poll_chats
+type memoryPollChat struct {
+	PollID int64
+	PollChat
+}
+
+// memoryVoteLog строка таблицы voting.vote_log
+type memoryVoteLog struct {
+	UserID    int64
+	PollID    int64
+	OptionID  int64
+	Action    string
+	ClickedAt time.Time
+}
+
+// MemoryStore хранилище голосований в памяти процесса.
+// Повторяет семантику PostgresStore (уникальные ключи, ON CONFLICT DO NOTHING,
+// ON DELETE CASCADE) и используется в тестах обработчиков.
+type MemoryStore struct {
+	mu     sync.Mutex
+	lastID int64 // общий счетчик ID для всех таблиц
+
+	polls     map[int64]*memoryPoll
+	options   []memoryOption // в порядке вставки (по возрастанию ID)
+	votes     []memoryVote   // в порядке вставки (по voted_at)
+	ballots   []memoryBallotRank
+	pollChats []memoryPollChat
+	voteLog   []memoryVoteLog
+	banned    map[int64]int64 // user_telegram_id -> banned_by
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		polls:  make(map[int64]*memoryPoll),
+		banned: make(map[int64]int64),
+	}
+}
+
+// nextID выдает следующий ID (вызывается под mu)
+func (s *MemoryStore) nextID() int64 {
+	s.lastID++
+	return s.lastID
+}
+
+// Ping всегда успешен
+func (s *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+// CreatePoll сохраняет голосование и варианты
+func (s *MemoryStore) CreatePoll(_ context.Context, creatorID int64, creatorUsername string, draft PollDraft) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	poll := &memoryPoll{
+		ID:              s.nextID(),
+		Title:           draft.Title,
+		Description:     draft.Description,
+		CreatorID:       creatorID,
+		CreatorUsername: creatorUsername,
+		IsActive:        true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		ExpiresAt:       draft.ExpiresAt,
+		AllowMultiple:   draft.AllowMultiple,
+		IsAnonymous:     draft.IsAnonymous,
+		VoteType:        draft.VoteType,
+	}
+	if poll.VoteType == "" {
+		poll.VoteType = VoteTypePlurality
+	}
+	if draft.AllowMultiple && draft.MaxSelections > 0 {
+		poll.MaxSelections = draft.MaxSelections
+	}
+	s.polls[poll.ID] = poll
+
+	for _, option := range draft.Options {
+		s.options = append(s.options, memoryOption{ID: s.nextID(), PollID: poll.ID, Text: option, Emoji: optionEmoji(option)})
+	}
+	return poll.ID, nil
+}
+
+// GetPoll возвращает голосование с вариантами, голосами и бюллетенями
+func (s *MemoryStore) GetPoll(_ context.Context, pollID int64) (*PollData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	poll, exists := s.polls[pollID]
+	if !exists {
+		return nil, errPollNotFound
+	}
+	return s.pollData(poll), nil
+}
+
+// pollData собирает PollData так же, как collectPolls и loadBallots (вызывается под mu)
+func (s *MemoryStore) pollData(poll *memoryPoll) *PollData {
+	data := &PollData{
+		ID:              poll.ID,
+		Title:           poll.Title,
+		Description:     poll.Description,
+		CreatorID:       poll.CreatorID,
+		CreatorUsername: poll.CreatorUsername,
+		IsActive:        poll.IsActive,
+		ExpiresAt:       poll.ExpiresAt,
+		AllowMultiple:   poll.AllowMultiple,
+		MaxSelections:   poll.MaxSelections,
+		IsAnonymous:     poll.IsAnonymous,
+		VoteType:        poll.VoteType,
+		Options:         make([]PollOption, 0),
+	}
+
+	for _, option := range s.options {
+		if option.PollID != poll.ID {
+			continue
+		}
+		emoji := "👍"
+		if option.Emoji != "" {
+			emoji = option.Emoji
+		}
+		opt := PollOption{ID: option.ID, Text: option.Text, Emoji: emoji, Votes: make([]Vote, 0)}
+		for _, vote := range s.votes {
+			if vote.PollID == poll.ID && vote.OptionID == option.ID {
+				opt.Votes = append(opt.Votes, vote.Voter)
+				data.TotalVotes++
+			}
+		}
+		data.Options = append(data.Options, opt)
+	}
+	data.countVoters()
+
+	if poll.VoteType == VoteTypeRanked {
+		// Бюллетени упорядочены по пользователю, варианты — по месту
+		ranks := make([]memoryBallotRank, 0)
+		for _, rank := range s.ballots {
+			if rank.PollID == poll.ID {
+				ranks = append(ranks, rank)
+			}
+		}
+		sort.SliceStable(ranks, func(i, j int) bool {
+			if ranks[i].Voter.UserID != ranks[j].Voter.UserID {
+				return ranks[i].Voter.UserID < ranks[j].Voter.UserID
+			}
+			return ranks[i].Rank < ranks[j].Rank
+		})
+
+		data.Ballots = make([][]int64, 0)
+		for i, rank := range ranks {
+			if i == 0 || rank.Voter.UserID != ranks[i-1].Voter.UserID {
+				data.Ballots = append(data.Ballots, make([]int64, 0))
+			}
+			last := len(data.Ballots) - 1
+			data.Ballots[last] = append(data.Ballots[last], rank.OptionID)
+		}
+		data.TotalVoters = len(data.Ballots)
+	}
+	return data
+}
+
+// activePollsOf возвращает активные голосования пользователя, новые первыми (вызывается под mu)
+func (s *MemoryStore) activePollsOf(creatorID int64) []*memoryPoll {
+	polls := make([]*memoryPoll, 0)
+	for _, poll := range s.polls {
+		if poll.IsActive && poll.CreatorID == creatorID {
+			polls = append(polls, poll)
+		}
+	}
+	sort.Slice(polls, func(i, j int) bool {
+		if !polls[i].CreatedAt.Equal(polls[j].CreatedAt) {
+			return polls[i].CreatedAt.After(polls[j].CreatedAt)
+		}
+		return polls[i].ID > polls[j].ID
+	})
+	return polls
+}
+
+// ListActivePolls возвращает последние активные голосования пользователя
+func (s *MemoryStore) ListActivePolls(_ context.Context, creatorID int64, limit int) ([]PollSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	polls := make([]PollSummary, 0)
+	for _, poll := range s.activePollsOf(creatorID) {
+		if len(polls) == limit {
+			break
+		}
+		polls = append(polls, PollSummary{ID: poll.ID, Title: poll.Title, CreatedAt: poll.CreatedAt})
+	}
+	return polls, nil
+}
+
+// SearchActivePolls ищет активные голосования пользователя по подстроке без учета регистра
+func (s *MemoryStore) SearchActivePolls(_ context.Context, creatorID int64, query string, limit, offset int) ([]*PollData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.ToLower(query)
+	matched := make([]*memoryPoll, 0)
+	for _, poll := range s.activePollsOf(creatorID) {
+		if query == "" ||
+			strings.Contains(strings.ToLower(poll.Title), query) ||
+			strings.Contains(strings.ToLower(poll.Description), query) {
+			matched = append(matched, poll)
+		}
+	}
+
+	polls := make([]*PollData, 0)
+	for i := offset; i < len(matched) && len(polls) < limit; i++ {
+		polls = append(polls, s.pollData(matched[i]))
+	}
+	return polls, nil
+}
+
+// SetPollActive меняет статус голосования
+func (s *MemoryStore) SetPollActive(_ context.Context, pollID int64, active bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	poll, exists := s.polls[pollID]
+	if !exists {
+		return errPollNotFound
+	}
+
+	now := time.Now()
+	poll.IsActive = active
+	poll.UpdatedAt = now
+	// Истекший срок сбрасываем, иначе голосование сразу снова окажется завершенным
+	if active && poll.ExpiresAt != nil && !poll.ExpiresAt.After(now) {
+		poll.ExpiresAt = nil
+	}
+	return nil
+}
+
+// UpdatePollText меняет заголовок и описание голосования
+func (s *MemoryStore) UpdatePollText(_ context.Context, pollID int64, title, description string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	poll, exists := s.polls[pollID]
+	if !exists {
+		return errPollNotFound
+	}
+	poll.Title = title
+	poll.Description = description
+	poll.UpdatedAt = time.Now()
+	return nil
+}
+
+// AddOption добавляет вариант в конец списка
+func (s *MemoryStore) AddOption(_ context.Context, pollID int64, text string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.polls[pollID]; !exists {
+		return 0, errPollNotFound
+	}
+	option := memoryOption{ID: s.nextID(), PollID: pollID, Text: text, Emoji: optionEmoji(text)}
+	s.options = append(s.options, option)
+	return option.ID, nil
+}
+
+// RenameOption меняет текст и эмодзи варианта
+func (s *MemoryStore) RenameOption(_ context.Context, pollID, optionID int64, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.options {
+		if s.options[i].ID == optionID && s.options[i].PollID == pollID {
+			s.options[i].Text = text
+			s.options[i].Emoji = optionEmoji(text)
+			return nil
+		}
+	}
+	return errOptionNotFound
+}
+
+// DeleteOption удаляет вариант, если за него никто не голосовал
+func (s *MemoryStore) DeleteOption(_ context.Context, pollID, optionID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+	remaining := 0
+	for _, option := range s.options {
+		if option.PollID != pollID {
+			continue
+		}
+		if option.ID == optionID {
+			found = true
+		} else {
+			remaining++
+		}
+	}
+	if !found {
+		return errOptionNotFound
+	}
+	for _, row := range s.votes {
+		if row.OptionID == optionID {
+			return errOptionHasVotes
+		}
+	}
+	for _, row := range s.ballots {
+		if row.OptionID == optionID {
+			return errOptionHasVotes
+		}
+	}
+	if remaining < 2 {
+		return errTooFewOptions
+	}
+
+	s.options = filterRows(s.options, func(row memoryOption) bool { return row.ID != optionID })
+	return nil
+}
+
+// CloseExpiredPolls завершает голосования с истекшим сроком
+func (s *MemoryStore) CloseExpiredPolls(_ context.Context) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	closed := make([]int64, 0)
+	for _, poll := range s.polls {
+		if poll.IsActive && poll.ExpiresAt != nil && !poll.ExpiresAt.After(now) {
+			poll.IsActive = false
+			poll.UpdatedAt = now
+			closed = append(closed, poll.ID)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i] < closed[j] })
+	return closed, nil
+}
+
+// DeletePoll удаляет голосование и все связанные строки (как ON DELETE CASCADE)
+func (s *MemoryStore) DeletePoll(_ context.Context, pollID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.polls[pollID]; !exists {
+		return errPollNotFound
+	}
+	delete(s.polls, pollID)
+
+	s.options = filterRows(s.options, func(row memoryOption) bool { return row.PollID != pollID })
+	s.votes = filterRows(s.votes, func(row memoryVote) bool { return row.PollID != pollID })
+	s.ballots = filterRows(s.ballots, func(row memoryBallotRank) bool { return row.PollID != pollID })
+	s.pollChats = filterRows(s.pollChats, func(row memoryPollChat) bool { return row.PollID != pollID })
+	return nil
+}
+
+// filterRows оставляет строки, для которых keep возвращает true
+func filterRows[T any](rows []T, keep func(T) bool) []T {
+	kept := rows[:0]
+	for _, row := range rows {
+		if keep(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// checkOpenPoll проверяет, что голосование существует и открыто (вызывается под mu)
+func (s *MemoryStore) checkOpenPoll(pollID int64) (*memoryPoll, error) {
+	poll, exists := s.polls[pollID]
+	if !exists {
+		return nil, errPollNotFound
+	}
+	if !poll.IsActive || (poll.ExpiresAt != nil && !poll.ExpiresAt.After(time.Now())) {
+		return nil, errPollClosed
+	}
+	return poll, nil
+}
+
+// hasOption проверяет, что вариант существует и принадлежит голосованию (вызывается под mu)
+func (s *MemoryStore) hasOption(pollID, optionID int64) bool {
+	for _, option := range s.options {
+		if option.ID == optionID && option.PollID == pollID {
+			return true
+		}
+	}
+	return false
+}
+
+// CastVote сохраняет голос по правилам голосования и записывает нажатие в vote_log
+func (s *MemoryStore) CastVote(_ context.Context, pollID, optionID int64, voter Vote) (VoteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	poll, err := s.checkOpenPoll(pollID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	if poll.VoteType == VoteTypeRanked {
+		return VoteResult{}, errPollRanked
+	}
+	if !s.hasOption(pollID, optionID) {
+		return VoteResult{}, errOptionNotFound
+	}
+
+	result := s.applyVote(poll, optionID, voter)
+	s.voteLog = append(s.voteLog, memoryVoteLog{
+		UserID: voter.UserID, PollID: pollID, OptionID: optionID,
+		Action: result.Outcome.logAction(), ClickedAt: time.Now(),
+	})
+	return result, nil
+}
+
+// LogThrottledVote записывает отклоненное нажатие в vote_log
+func (s *MemoryStore) LogThrottledVote(_ context.Context, pollID, optionID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.voteLog = append(s.voteLog, memoryVoteLog{
+		UserID: userID, PollID: pollID, OptionID: optionID,
+		Action: voteActionThrottled, ClickedAt: time.Now(),
+	})
+	return nil
+}
+
+// applyVote меняет голоса пользователя по правилам голосования (вызывается под mu)
+func (s *MemoryStore) applyVote(poll *memoryPoll, optionID int64, voter Vote) VoteResult {
+	pollID := poll.ID
+	isMine := func(row memoryVote) bool { return row.PollID == pollID && row.Voter.UserID == voter.UserID }
+
+	if !poll.AllowMultiple {
+		// Предыдущий выбор пользователя заменяется, повторное нажатие отзывает голос
+		previous := make([]int64, 0, 1)
+		s.votes = filterRows(s.votes, func(row memoryVote) bool {
+			if isMine(row) {
+				previous = append(previous, row.OptionID)
+				return false
+			}
+			return true
+		})
+		if slices.Contains(previous, optionID) {
+			return VoteResult{Outcome: VoteWithdrawn}
+		}
+		s.insertVote(pollID, optionID, voter)
+		if len(previous) > 0 {
+			return VoteResult{Outcome: VoteChanged}
+		}
+		return VoteResult{Outcome: VoteRecorded}
+	}
+
+	// Повторное нажатие снимает выбор
+	before := len(s.votes)
+	s.votes = filterRows(s.votes, func(row memoryVote) bool { return !isMine(row) || row.OptionID != optionID })
+	if len(s.votes) < before {
+		return VoteResult{Outcome: VoteDeselected}
+	}
+
+	if poll.MaxSelections > 0 {
+		selected := 0
+		for _, row := range s.votes {
+			if isMine(row) {
+				selected++
+			}
+		}
+		if selected >= poll.MaxSelections {
+			return VoteResult{Outcome: VoteLimitReached, MaxSelections: poll.MaxSelections}
+		}
+	}
+
+	s.insertVote(pollID, optionID, voter)
+	return VoteResult{Outcome: VoteSelected}
+}
+
+// insertVote добавляет голос; повторный голос за тот же вариант игнорируется
+// (ON CONFLICT (poll_id, user_telegram_id, option_id) DO NOTHING, вызывается под mu)
+func (s *MemoryStore) insertVote(pollID, optionID int64, voter Vote) {
+	for _, row := range s.votes {
+		if row.PollID == pollID && row.Voter.UserID == voter.UserID && row.OptionID == optionID {
+			return
+		}
+	}
+	s.votes = append(s.votes, memoryVote{PollID: pollID, OptionID: optionID, Voter: voter, VotedAt: time.Now()})
+}
+
+// SaveBallot сохраняет (или заменяет) бюллетень рейтингового голосования
+func (s *MemoryStore) SaveBallot(_ context.Context, pollID int64, voter Vote, order []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	poll, exists := s.polls[pollID]
+	if !exists {
+		return errPollNotFound
+	}
+	if poll.VoteType != VoteTypeRanked {
+		return errPollNotRanked
+	}
+	if _, err := s.checkOpenPoll(pollID); err != nil {
+		return err
+	}
+
+	// Проверяем ограничения до изменений, чтобы ошибка не оставила бюллетень частично записанным
+	seen := make(map[int64]bool, len(order))
+	for _, optionID := range order {
+		if !s.hasOption(pollID, optionID) {
+			return fmt.Errorf("ошибка сохранения бюллетеня: вариант %d: %w", optionID, errOptionNotFound)
+		}
+		if seen[optionID] {
+			return fmt.Errorf("ошибка сохранения бюллетеня: вариант %d указан дважды", optionID)
+		}
+		seen[optionID] = true
+	}
+
+	now := time.Now()
+	s.voteLog = append(s.voteLog, memoryVoteLog{UserID: voter.UserID, PollID: pollID, OptionID: order[0], Action: voteActionBallot, ClickedAt: now})
+
+	s.ballots = filterRows(s.ballots, func(row memoryBallotRank) bool {
+		return row.PollID != pollID || row.Voter.UserID != voter.UserID
+	})
+	for i, optionID := range order {
+		s.ballots = append(s.ballots, memoryBallotRank{PollID: pollID, OptionID: optionID, Rank: i + 1, Voter: voter, SubmittedAt: now})
+	}
+	return nil
+}
+
+// ListVoteRecords возвращает голоса (или строки бюллетеней) голосования
+func (s *MemoryStore) ListVoteRecords(_ context.Context, pollID int64) ([]VoteRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]VoteRecord, 0)
+	for _, row := range s.votes {
+		if row.PollID == pollID {
+			records = append(records, VoteRecord{Voter: row.Voter, OptionID: row.OptionID, VotedAt: row.VotedAt})
+		}
+	}
+	for _, row := range s.ballots {
+		if row.PollID == pollID {
+			records = append(records, VoteRecord{Voter: row.Voter, OptionID: row.OptionID, Rank: row.Rank, VotedAt: row.SubmittedAt})
+		}
+	}
+	return records, nil
+}
+
+// ListVoteLog возвращает историю нажатий голосования
+func (s *MemoryStore) ListVoteLog(_ context.Context, pollID int64) ([]VoteLogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]VoteLogEntry, 0)
+	for _, row := range s.voteLog {
+		if row.PollID == pollID {
+			entries = append(entries, VoteLogEntry{UserID: row.UserID, OptionID: row.OptionID, Action: row.Action, ClickedAt: row.ClickedAt})
+		}
+	}
+	return entries, nil
+}
+
+// AddPollChat запоминает публикацию в чате (ON CONFLICT (poll_id, chat_id, message_id) DO NOTHING)
+func (s *MemoryStore) AddPollChat(_ context.Context, pollID, chatID, messageID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.polls[pollID]; !exists {
+		return fmt.Errorf("ошибка сохранения публикации: голосование %d не существует", pollID)
+	}
+	for _, chat := range s.pollChats {
+		if chat.PollID == pollID && chat.ChatID == chatID && chat.MessageID == messageID {
+			return nil
+		}
+	}
+	s.pollChats = append(s.pollChats, memoryPollChat{
+		PollID:   pollID,
+		PollChat: PollChat{ID: s.nextID(), ChatID: chatID, MessageID: messageID},
+	})
+	return nil
+}
+
+// AddInlinePollChat запоминает inline-публикацию (ON CONFLICT (poll_id, inline_message_id) DO NOTHING)
+func (s *MemoryStore) AddInlinePollChat(_ context.Context, pollID int64, inlineMessageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.polls[pollID]; !exists {
+		return fmt.Errorf("ошибка сохранения inline-публикации: голосование %d не существует", pollID)
+	}
+	for _, chat := range s.pollChats {
+		if chat.PollID == pollID && chat.InlineMessageID == inlineMessageID {
+			return nil
+		}
+	}
+	hash := int64(0)
+	s.pollChats = append(s.pollChats, memoryPollChat{
+		PollID:   pollID,
+		PollChat: PollChat{ID: s.nextID(), InlineMessageID: inlineMessageID, MessageHash: &hash},
+	})
+	return nil
+}
+
+// ListPollChats возвращает публикации голосования
+func (s *MemoryStore) ListPollChats(_ context.Context, pollID int64) ([]PollChat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chats := make([]PollChat, 0)
+	for _, row := range s.pollChats {
+		if row.PollID != pollID {
+			continue
+		}
+		chat := row.PollChat
+		if chat.MessageHash != nil {
+			hash := *chat.MessageHash
+			chat.MessageHash = &hash
+		}
+		chats = append(chats, chat)
+	}
+	return chats, nil
+}
+
+// SetPollChatHash сохраняет хеш отрисованного сообщения
+func (s *MemoryStore) SetPollChatHash(_ context.Context, pollChatID, hash int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.pollChats {
+		if s.pollChats[i].ID == pollChatID {
+			s.pollChats[i].MessageHash = &hash
+		}
+	}
+	return nil
+}
+
+// GetStats считает строки так же, как запрос PostgresStore.GetStats
+func (s *MemoryStore) GetStats(_ context.Context) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	stats := Stats{
+		Polls:        len(s.polls),
+		Votes:        len(s.votes),
+		Publications: len(s.pollChats),
+		VoteLog:      len(s.voteLog),
+		BannedUsers:  len(s.banned),
+	}
+	creators := make(map[int64]struct{})
+	for _, poll := range s.polls {
+		creators[poll.CreatorID] = struct{}{}
+		if poll.IsActive && (poll.ExpiresAt == nil || poll.ExpiresAt.After(now)) {
+			stats.ActivePolls++
+		}
+	}
+	stats.Creators = len(creators)
+
+	voters := make(map[int64]struct{})
+	for _, vote := range s.votes {
+		voters[vote.Voter.UserID] = struct{}{}
+	}
+	for _, rank := range s.ballots {
+		voters[rank.Voter.UserID] = struct{}{}
+		if rank.Rank == 1 {
+			stats.Ballots++
+		}
+	}
+	stats.Voters = len(voters)
+	return stats, nil
+}
+
+// BanUser блокирует пользователя (ON CONFLICT DO NOTHING)
+func (s *MemoryStore) BanUser(_ context.Context, userID, adminID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.banned[userID]; !exists {
+		s.banned[userID] = adminID
+	}
+	return nil
+}
+
+// UnbanUser снимает блокировку
+func (s *MemoryStore) UnbanUser(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.banned[userID]; !exists {
+		return errUserNotBanned
+	}
+	delete(s.banned, userID)
+	return nil
+}
+
+// ListBannedUsers возвращает заблокированных пользователей по возрастанию ID
+func (s *MemoryStore) ListBannedUsers(_ context.Context) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]int64, 0, len(s.banned))
+	for userID := range s.banned {
+		users = append(users, userID)
+	}
+	slices.Sort(users)
+	return users, nil
+}
+
+// FindUserID ищет пользователя по username среди авторов, голосов и бюллетеней
+func (s *MemoryStore) FindUserID(_ context.Context, username string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var userID int64
+	var seenAt time.Time
+	found := false
+	consider := func(name string, id int64, at time.Time) {
+		if strings.EqualFold(name, username) && (!found || at.After(seenAt)) {
+			userID, seenAt, found = id, at, true
+		}
+	}
+	for _, poll := range s.polls {
+		consider(poll.CreatorUsername, poll.CreatorID, poll.CreatedAt)
+	}
+	for _, vote := range s.votes {
+		consider(vote.Voter.Username, vote.Voter.UserID, vote.VotedAt)
+	}
+	for _, rank := range s.ballots {
+		consider(rank.Voter.Username, rank.Voter.UserID, rank.SubmittedAt)
+	}
+	if !found {
+		return 0, errUserNotFound
+	}
+	return userID, nil
+}
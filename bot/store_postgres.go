@@ -0,0 +1,934 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore хранилище голосований в PostgreSQL (схема voting)
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresStore создает хранилище голосований поверх пула соединений
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Ping проверяет соединение с базой данных
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	var result string
+	return s.db.QueryRow(ctx, "SELECT version()").Scan(&result)
+}
+
+// CreatePoll сохраняет голосование и варианты в одной транзакции
+func (s *PostgresStore) CreatePoll(ctx context.Context, creatorID int64, creatorUsername string, draft PollDraft) (int64, error) {
+	// Начинаем транзакцию
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Ограничение на число выбранных вариантов (NULL — без ограничений)
+	var maxSelections *int
+	if draft.AllowMultiple && draft.MaxSelections > 0 {
+		maxSelections = &draft.MaxSelections
+	}
+
+	// Вставляем голосование
+	var pollID int64
+	err = tx.QueryRow(ctx,
+		`INSERT INTO voting.polls (title, description, creator_telegram_id, creator_username, is_active, created_at, updated_at, expires_at, allow_multiple, max_selections, is_anonymous, vote_type)
+		 VALUES ($1, NULLIF($2, ''), $3, $4, true, NOW(), NOW(), $5, $6, $7, $8, $9)
+		 RETURNING id`,
+		draft.Title, draft.Description, creatorID, creatorUsername, draft.ExpiresAt, draft.AllowMultiple, maxSelections, draft.IsAnonymous, draft.VoteType,
+	).Scan(&pollID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания голосования: %w", err)
+	}
+
+	// Вставляем варианты ответов
+	for _, option := range draft.Options {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO voting.poll_options (poll_id, option_text, emoji, created_at)
+			 VALUES ($1, $2, NULLIF($3, ''), NOW())`,
+			pollID, option, optionEmoji(option),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка добавления варианта '%s': %w", option, err)
+		}
+	}
+
+	// Коммитим транзакцию
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+
+	return pollID, nil
+}
+
+// GetPoll получает голосование одним запросом с JOIN
+func (s *PostgresStore) GetPoll(ctx context.Context, pollID int64) (*PollData, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT
+		     p.id, p.title, p.description, p.creator_telegram_id, p.creator_username, p.is_active, p.expires_at, p.allow_multiple, p.max_selections, p.is_anonymous, p.vote_type,
+		     po.id as option_id, po.option_text, po.emoji,
+		     v.user_telegram_id, v.user_username, v.user_first_name, v.user_last_name
+		 FROM voting.polls p
+		 LEFT JOIN voting.poll_options po ON po.poll_id = p.id
+		 LEFT JOIN voting.votes v ON v.poll_id = p.id AND v.option_id = po.id
+		 WHERE p.id = $1
+		 ORDER BY po.id, v.voted_at`,
+		pollID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения данных голосования: %w", err)
+	}
+
+	polls, err := collectPolls(rows)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения данных голосования: %w", err)
+	}
+	if len(polls) == 0 {
+		return nil, errPollNotFound
+	}
+
+	poll := polls[0]
+	if err := s.loadBallots(ctx, poll); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// ListActivePolls возвращает последние активные голосования пользователя
+func (s *PostgresStore) ListActivePolls(ctx context.Context, creatorID int64, limit int) ([]PollSummary, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT id, title, created_at
+		 FROM voting.polls
+		 WHERE is_active = true AND creator_telegram_id = $1
+		 ORDER BY created_at DESC
+		 LIMIT $2`,
+		creatorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка голосований: %w", err)
+	}
+	defer rows.Close()
+
+	polls := make([]PollSummary, 0)
+	for rows.Next() {
+		var poll PollSummary
+		if err := rows.Scan(&poll.ID, &poll.Title, &poll.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных голосования: %w", err)
+		}
+		polls = append(polls, poll)
+	}
+	return polls, rows.Err()
+}
+
+// SearchActivePolls получает активные голосования с вариантами и голосами одним запросом (избегаем N+1).
+// Поиск по названию и описанию без учета регистра использует триграммные индексы.
+func (s *PostgresStore) SearchActivePolls(ctx context.Context, creatorID int64, query string, limit, offset int) ([]*PollData, error) {
+	rows, err := s.db.Query(ctx,
+		`WITH recent_polls AS (
+		     SELECT id, title, description, creator_telegram_id, creator_username, is_active, created_at, expires_at, allow_multiple, max_selections, is_anonymous, vote_type
+		     FROM voting.polls
+		     WHERE is_active = true
+		       AND creator_telegram_id = $1
+		       AND ($2 = '' OR title ILIKE '%' || $2 || '%' OR description ILIKE '%' || $2 || '%')
+		     ORDER BY created_at DESC, id DESC
+		     LIMIT $3 OFFSET $4
+		 )
+		 SELECT
+		     p.id, p.title, p.description, p.creator_telegram_id, p.creator_username, p.is_active, p.expires_at, p.allow_multiple, p.max_selections, p.is_anonymous, p.vote_type,
+		     po.id as option_id, po.option_text, po.emoji,
+		     v.user_telegram_id, v.user_username, v.user_first_name, v.user_last_name
+		 FROM recent_polls p
+		 LEFT JOIN voting.poll_options po ON po.poll_id = p.id
+		 LEFT JOIN voting.votes v ON v.option_id = po.id AND v.poll_id = p.id
+		 ORDER BY p.created_at DESC, p.id DESC, po.id, v.voted_at`,
+		creatorID, escapeLikePattern(query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска голосований: %w", err)
+	}
+
+	polls, err := collectPolls(rows)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения данных голосования: %w", err)
+	}
+
+	// Бюллетени рейтинговых голосований загружаются отдельно
+	for _, poll := range polls {
+		if err := s.loadBallots(ctx, poll); err != nil {
+			return nil, err
+		}
+	}
+	return polls, nil
+}
+
+// collectPolls собирает голосования из плоского результата JOIN polls/poll_options/votes.
+// Порядок голосований, вариантов и голосов сохраняется как в запросе.
+func collectPolls(rows pgx.Rows) ([]*PollData, error) {
+	defer rows.Close()
+
+	polls := make([]*PollData, 0)
+	pollsMap := make(map[int64]*PollData)
+	optionIndex := make(map[int64]int) // optionID -> индекс в poll.Options
+
+	for rows.Next() {
+		var pollID int64
+		var title string
+		var description *string
+		var creatorID int64
+		var creatorUsername *string
+		var isActive *bool
+		var expiresAt *time.Time
+		var allowMultiple bool
+		var maxSelections *int
+		var isAnonymous bool
+		var voteType string
+		var optionID *int64
+		var optionText *string
+		var emoji *string
+		var voteUserID *int64
+		var voteUsername *string
+		var voteFirstName *string
+		var voteLastName *string
+
+		if err := rows.Scan(&pollID, &title, &description, &creatorID, &creatorUsername, &isActive, &expiresAt, &allowMultiple, &maxSelections, &isAnonymous, &voteType,
+			&optionID, &optionText, &emoji,
+			&voteUserID, &voteUsername, &voteFirstName, &voteLastName); err != nil {
+			return nil, err
+		}
+
+		// Создаем или получаем голосование
+		poll, exists := pollsMap[pollID]
+		if !exists {
+			poll = &PollData{
+				ID:            pollID,
+				Title:         title,
+				CreatorID:     creatorID,
+				IsActive:      isActive == nil || *isActive,
+				ExpiresAt:     expiresAt,
+				AllowMultiple: allowMultiple,
+				IsAnonymous:   isAnonymous,
+				VoteType:      voteType,
+				Options:       make([]PollOption, 0),
+			}
+			if maxSelections != nil {
+				poll.MaxSelections = *maxSelections
+			}
+			if description != nil {
+				poll.Description = *description
+			}
+			if creatorUsername != nil {
+				poll.CreatorUsername = *creatorUsername
+			}
+			pollsMap[pollID] = poll
+			polls = append(polls, poll)
+		}
+
+		if optionID == nil || optionText == nil {
+			continue
+		}
+
+		// Добавляем вариант, если его еще нет
+		index, exists := optionIndex[*optionID]
+		if !exists {
+			emojiValue := "👍"
+			if emoji != nil && *emoji != "" {
+				emojiValue = *emoji
+			}
+			poll.Options = append(poll.Options, PollOption{
+				ID:    *optionID,
+				Text:  *optionText,
+				Emoji: emojiValue,
+				Votes: make([]Vote, 0),
+			})
+			index = len(poll.Options) - 1
+			optionIndex[*optionID] = index
+		}
+
+		// Добавляем голос, если есть
+		if voteUserID != nil {
+			vote := Vote{
+				UserID: *voteUserID,
+			}
+			if voteUsername != nil {
+				vote.Username = *voteUsername
+			}
+			if voteFirstName != nil {
+				vote.FirstName = *voteFirstName
+			}
+			if voteLastName != nil {
+				vote.LastName = *voteLastName
+			}
+			poll.Options[index].Votes = append(poll.Options[index].Votes, vote)
+			poll.TotalVotes++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, poll := range polls {
+		poll.countVoters()
+	}
+	return polls, nil
+}
+
+// loadBallots загружает бюллетени рейтингового голосования в PollData.Ballots
+func (s *PostgresStore) loadBallots(ctx context.Context, poll *PollData) error {
+	if poll.VoteType != VoteTypeRanked {
+		return nil
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT user_telegram_id, option_id
+		 FROM voting.ballots
+		 WHERE poll_id = $1
+		 ORDER BY user_telegram_id, rank`,
+		poll.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения бюллетеней: %w", err)
+	}
+	defer rows.Close()
+
+	poll.Ballots = make([][]int64, 0)
+	var lastUserID int64
+	for rows.Next() {
+		var userID, optionID int64
+		if err := rows.Scan(&userID, &optionID); err != nil {
+			return err
+		}
+		if len(poll.Ballots) == 0 || userID != lastUserID {
+			poll.Ballots = append(poll.Ballots, make([]int64, 0))
+			lastUserID = userID
+		}
+		last := len(poll.Ballots) - 1
+		poll.Ballots[last] = append(poll.Ballots[last], optionID)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	poll.TotalVoters = len(poll.Ballots)
+	return nil
+}
+
+// SetPollActive меняет статус голосования
+func (s *PostgresStore) SetPollActive(ctx context.Context, pollID int64, active bool) error {
+	var tag pgconn.CommandTag
+	var err error
+	if active {
+		// Истекший срок сбрасываем, иначе голосование сразу снова окажется завершенным
+		tag, err = s.db.Exec(ctx,
+			`UPDATE voting.polls
+			 SET is_active = true,
+			     expires_at = CASE WHEN expires_at <= NOW() THEN NULL ELSE expires_at END,
+			     updated_at = NOW()
+			 WHERE id = $1`,
+			pollID)
+	} else {
+		tag, err = s.db.Exec(ctx,
+			`UPDATE voting.polls SET is_active = false, updated_at = NOW() WHERE id = $1`,
+			pollID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка изменения статуса голосования: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errPollNotFound
+	}
+	return nil
+}
+
+// UpdatePollText меняет заголовок и описание голосования
+func (s *PostgresStore) UpdatePollText(ctx context.Context, pollID int64, title, description string) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE voting.polls
+		 SET title = $2, description = NULLIF($3, ''), updated_at = NOW()
+		 WHERE id = $1`,
+		pollID, title, description)
+	if err != nil {
+		return fmt.Errorf("ошибка изменения голосования: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errPollNotFound
+	}
+	return nil
+}
+
+// AddOption добавляет вариант в голосование
+func (s *PostgresStore) AddOption(ctx context.Context, pollID int64, text string) (int64, error) {
+	var optionID int64
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO voting.poll_options (poll_id, option_text, emoji, created_at)
+		 SELECT id, $2, NULLIF($3, ''), NOW() FROM voting.polls WHERE id = $1
+		 RETURNING id`,
+		pollID, text, optionEmoji(text)).Scan(&optionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errPollNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка добавления варианта '%s': %w", text, err)
+	}
+	return optionID, nil
+}
+
+// RenameOption меняет текст и эмодзи варианта
+func (s *PostgresStore) RenameOption(ctx context.Context, pollID, optionID int64, text string) error {
+	tag, err := s.db.Exec(ctx,
+		`UPDATE voting.poll_options
+		 SET option_text = $3, emoji = NULLIF($4, '')
+		 WHERE id = $2 AND poll_id = $1`,
+		pollID, optionID, text, optionEmoji(text))
+	if err != nil {
+		return fmt.Errorf("ошибка изменения варианта: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errOptionNotFound
+	}
+	return nil
+}
+
+// DeleteOption удаляет вариант без голосов. Строка голосования блокируется FOR UPDATE:
+// CastVote берет ее FOR SHARE, поэтому голос не может появиться между проверкой и удалением
+func (s *PostgresStore) DeleteOption(ctx context.Context, pollID, optionID int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT 1 FROM voting.polls WHERE id = $1 FOR UPDATE`, pollID)
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки голосования: %w", err)
+	}
+
+	var found, hasVotes bool
+	var remaining int
+	err = tx.QueryRow(ctx,
+		`SELECT
+		     EXISTS (SELECT 1 FROM voting.poll_options WHERE id = $2 AND poll_id = $1),
+		     EXISTS (SELECT 1 FROM voting.votes WHERE option_id = $2)
+		         OR EXISTS (SELECT 1 FROM voting.ballots WHERE option_id = $2),
+		     (SELECT COUNT(*) FROM voting.poll_options WHERE poll_id = $1 AND id != $2)`,
+		pollID, optionID).Scan(&found, &hasVotes, &remaining)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки варианта: %w", err)
+	}
+	switch {
+	case !found:
+		return errOptionNotFound
+	case hasVotes:
+		return errOptionHasVotes
+	case remaining < 2:
+		return errTooFewOptions
+	}
+
+	if _, err = tx.Exec(ctx, `DELETE FROM voting.poll_options WHERE id = $1`, optionID); err != nil {
+		return fmt.Errorf("ошибка удаления варианта: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// CloseExpiredPolls переводит голосования с истекшим сроком в неактивные
+func (s *PostgresStore) CloseExpiredPolls(ctx context.Context) ([]int64, error) {
+	rows, err := s.db.Query(ctx,
+		`UPDATE voting.polls
+		 SET is_active = false, updated_at = NOW()
+		 WHERE is_active = true AND expires_at IS NOT NULL AND expires_at <= NOW()
+		 RETURNING id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка закрытия голосований: %w", err)
+	}
+	defer rows.Close()
+
+	closed := make([]int64, 0)
+	for rows.Next() {
+		var pollID int64
+		if err := rows.Scan(&pollID); err != nil {
+			return nil, fmt.Errorf("ошибка чтения ID голосования: %w", err)
+		}
+		closed = append(closed, pollID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка закрытия голосований: %w", err)
+	}
+	return closed, nil
+}
+
+// DeletePoll удаляет голосование; связанные строки удаляются каскадом (ON DELETE CASCADE)
+func (s *PostgresStore) DeletePoll(ctx context.Context, pollID int64) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM voting.polls WHERE id = $1`, pollID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления голосования: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errPollNotFound
+	}
+	return nil
+}
+
+// CastVote сохраняет голос в транзакции вместе с записью в vote_log
+func (s *PostgresStore) CastVote(ctx context.Context, pollID, optionID int64, voter Vote) (VoteResult, error) {
+	// Начинаем транзакцию
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Проверяем, что голосование еще открыто (блокируем строку от закрытия до конца транзакции)
+	var isActive bool
+	var expiresAt *time.Time
+	var allowMultiple bool
+	var maxSelections *int
+	var voteType string
+	err = tx.QueryRow(ctx,
+		`SELECT is_active, expires_at, allow_multiple, max_selections, vote_type FROM voting.polls WHERE id = $1 FOR SHARE`,
+		pollID).Scan(&isActive, &expiresAt, &allowMultiple, &maxSelections, &voteType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return VoteResult{}, errPollNotFound
+	}
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка проверки статуса голосования: %w", err)
+	}
+	if !isActive || (expiresAt != nil && !expiresAt.After(time.Now())) {
+		return VoteResult{}, errPollClosed
+	}
+	if voteType == VoteTypeRanked {
+		return VoteResult{}, errPollRanked
+	}
+
+	// Вариант должен принадлежать этому голосованию: внешний ключ проверяет только его существование
+	var optionFound bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM voting.poll_options WHERE id = $2 AND poll_id = $1)`,
+		pollID, optionID).Scan(&optionFound)
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка проверки варианта: %w", err)
+	}
+	if !optionFound {
+		return VoteResult{}, errOptionNotFound
+	}
+
+	// Сериализуем нажатия одного пользователя в этом голосовании: лимит выбора проверяется
+	// приложением, а один голос в режиме одного варианта — еще и триггером trg_votes_single_choice
+	_, err = tx.Exec(ctx,
+		`SELECT pg_advisory_xact_lock(hashtextextended('vote:' || $1::text || ':' || $2::text, 0))`,
+		pollID, voter.UserID)
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка блокировки голоса: %w", err)
+	}
+
+	var result VoteResult
+	if allowMultiple {
+		result, err = toggleMultipleVote(ctx, tx, pollID, optionID, voter, maxSelections)
+	} else {
+		result, err = saveSingleVote(ctx, tx, pollID, optionID, voter)
+	}
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка сохранения голоса: %w", err)
+	}
+
+	// Логируем нажатие на кнопку в vote_log (append-only) вместе с его итогом
+	_, err = tx.Exec(ctx,
+		`INSERT INTO voting.vote_log (user_telegram_id, poll_id, option_id, action)
+		 VALUES ($1, $2, $3, $4)`,
+		voter.UserID, pollID, optionID, result.Outcome.logAction())
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка записи в vote_log: %w", err)
+	}
+
+	// Фиксируем транзакцию (нажатие сохраняется в vote_log, даже если выбор отклонен)
+	if err = tx.Commit(ctx); err != nil {
+		return VoteResult{}, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return result, nil
+}
+
+// LogThrottledVote записывает отклоненное нажатие одной вставкой, без транзакции и блокировок
+func (s *PostgresStore) LogThrottledVote(ctx context.Context, pollID, optionID, userID int64) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO voting.vote_log (user_telegram_id, poll_id, option_id, action)
+		 VALUES ($1, $2, $3, $4)`,
+		userID, pollID, optionID, voteActionThrottled)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в vote_log: %w", err)
+	}
+	return nil
+}
+
+// saveSingleVote сохраняет голос в голосовании с одним вариантом: предыдущий выбор пользователя
+// заменяется, а повторное нажатие на текущий вариант отзывает голос
+func saveSingleVote(ctx context.Context, tx pgx.Tx, pollID, optionID int64, voter Vote) (VoteResult, error) {
+	rows, err := tx.Query(ctx,
+		`DELETE FROM voting.votes WHERE poll_id = $1 AND user_telegram_id = $2 RETURNING option_id`,
+		pollID, voter.UserID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	previous, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return VoteResult{}, err
+	}
+	if slices.Contains(previous, optionID) {
+		return VoteResult{Outcome: VoteWithdrawn}, nil
+	}
+
+	if err := insertVote(ctx, tx, pollID, optionID, voter); err != nil {
+		return VoteResult{}, err
+	}
+	if len(previous) > 0 {
+		return VoteResult{Outcome: VoteChanged}, nil
+	}
+	return VoteResult{Outcome: VoteRecorded}, nil
+}
+
+// toggleMultipleVote переключает вариант в голосовании с множественным выбором:
+// повторное нажатие снимает выбор, новое — добавляет, если не превышен лимит
+func toggleMultipleVote(ctx context.Context, tx pgx.Tx, pollID, optionID int64, voter Vote, maxSelections *int) (VoteResult, error) {
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM voting.votes WHERE poll_id = $1 AND user_telegram_id = $2 AND option_id = $3`,
+		pollID, voter.UserID, optionID)
+	if err != nil {
+		return VoteResult{}, err
+	}
+	if tag.RowsAffected() > 0 {
+		return VoteResult{Outcome: VoteDeselected}, nil
+	}
+
+	if maxSelections != nil {
+		var selected int
+		err = tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM voting.votes WHERE poll_id = $1 AND user_telegram_id = $2`,
+			pollID, voter.UserID).Scan(&selected)
+		if err != nil {
+			return VoteResult{}, err
+		}
+		if selected >= *maxSelections {
+			return VoteResult{Outcome: VoteLimitReached, MaxSelections: *maxSelections}, nil
+		}
+	}
+
+	if err := insertVote(ctx, tx, pollID, optionID, voter); err != nil {
+		return VoteResult{}, err
+	}
+	return VoteResult{Outcome: VoteSelected}, nil
+}
+
+// insertVote добавляет голос за вариант (повторный голос за тот же вариант игнорируется)
+func insertVote(ctx context.Context, tx pgx.Tx, pollID, optionID int64, voter Vote) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO voting.votes (poll_id, option_id, user_telegram_id, user_username, user_first_name, user_last_name)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (poll_id, user_telegram_id, option_id) DO NOTHING`,
+		pollID, optionID, voter.UserID, voter.Username, voter.FirstName, voter.LastName)
+	return err
+}
+
+// SaveBallot сохраняет (или заменяет) бюллетень пользователя в рейтинговом голосовании
+func (s *PostgresStore) SaveBallot(ctx context.Context, pollID int64, voter Vote, order []int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Голосование должно быть открыто до конца транзакции
+	var isActive bool
+	var expiresAt *time.Time
+	var voteType string
+	err = tx.QueryRow(ctx,
+		`SELECT is_active, expires_at, vote_type FROM voting.polls WHERE id = $1 FOR SHARE`,
+		pollID).Scan(&isActive, &expiresAt, &voteType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errPollNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка проверки статуса голосования: %w", err)
+	}
+	if voteType != VoteTypeRanked {
+		return errPollNotRanked
+	}
+	if !isActive || (expiresAt != nil && !expiresAt.After(time.Now())) {
+		return errPollClosed
+	}
+
+	// Все варианты бюллетеня должны принадлежать этому голосованию
+	var known int
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM voting.poll_options WHERE poll_id = $1 AND id = ANY($2)`,
+		pollID, order).Scan(&known)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки вариантов: %w", err)
+	}
+	if known != len(order) {
+		return fmt.Errorf("ошибка сохранения бюллетеня: %w", errOptionNotFound)
+	}
+
+	// Логируем отправку бюллетеня (первое предпочтение) в vote_log
+	_, err = tx.Exec(ctx,
+		`INSERT INTO voting.vote_log (user_telegram_id, poll_id, option_id, action)
+		 VALUES ($1, $2, $3, $4)`,
+		voter.UserID, pollID, order[0], voteActionBallot)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в vote_log: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		`DELETE FROM voting.ballots WHERE poll_id = $1 AND user_telegram_id = $2`,
+		pollID, voter.UserID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления предыдущего бюллетеня: %w", err)
+	}
+
+	for i, optionID := range order {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO voting.ballots (poll_id, option_id, rank, user_telegram_id, user_username, user_first_name, user_last_name)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			pollID, optionID, i+1, voter.UserID, voter.Username, voter.FirstName, voter.LastName)
+		if err != nil {
+			return fmt.Errorf("ошибка сохранения бюллетеня: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return nil
+}
+
+// ListVoteRecords возвращает голоса голосования, а для рейтингового — строки бюллетеней
+func (s *PostgresStore) ListVoteRecords(ctx context.Context, pollID int64) ([]VoteRecord, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT option_id, 0 AS rank, user_telegram_id, user_username, user_first_name, user_last_name, voted_at
+		 FROM voting.votes
+		 WHERE poll_id = $1
+		 UNION ALL
+		 SELECT option_id, rank, user_telegram_id, user_username, user_first_name, user_last_name, submitted_at
+		 FROM voting.ballots
+		 WHERE poll_id = $1
+		 ORDER BY 7, 3, 2`,
+		pollID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения голосов: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]VoteRecord, 0)
+	for rows.Next() {
+		var record VoteRecord
+		var username, firstName, lastName *string
+		if err := rows.Scan(&record.OptionID, &record.Rank, &record.Voter.UserID, &username, &firstName, &lastName, &record.VotedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения голосов: %w", err)
+		}
+		if username != nil {
+			record.Voter.Username = *username
+		}
+		if firstName != nil {
+			record.Voter.FirstName = *firstName
+		}
+		if lastName != nil {
+			record.Voter.LastName = *lastName
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения голосов: %w", err)
+	}
+	return records, nil
+}
+
+// ListVoteLog возвращает историю нажатий голосования
+func (s *PostgresStore) ListVoteLog(ctx context.Context, pollID int64) ([]VoteLogEntry, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT user_telegram_id, option_id, COALESCE(action, ''), clicked_at
+		 FROM voting.vote_log
+		 WHERE poll_id = $1
+		 ORDER BY clicked_at, id`,
+		pollID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения истории нажатий: %w", err)
+	}
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByPos[VoteLogEntry])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения истории нажатий: %w", err)
+	}
+	return entries, nil
+}
+
+// AddPollChat сохраняет информацию о публикации голосования в чате
+func (s *PostgresStore) AddPollChat(ctx context.Context, pollID, chatID, messageID int64) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO voting.poll_chats (poll_id, chat_id, message_id)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (poll_id, chat_id, message_id) DO NOTHING`,
+		pollID, chatID, messageID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения публикации: %w", err)
+	}
+	return nil
+}
+
+// AddInlinePollChat сохраняет информацию об отправке inline-голосования
+func (s *PostgresStore) AddInlinePollChat(ctx context.Context, pollID int64, inlineMessageID string) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO voting.poll_chats (poll_id, inline_message_id, message_hash, created_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (poll_id, inline_message_id) WHERE inline_message_id IS NOT NULL
+		 DO NOTHING`,
+		pollID, inlineMessageID, int64(0))
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения inline-публикации: %w", err)
+	}
+	return nil
+}
+
+// ListPollChats возвращает все опубликованные сообщения голосования (включая хеш)
+func (s *PostgresStore) ListPollChats(ctx context.Context, pollID int64) ([]PollChat, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT id, chat_id, message_id, inline_message_id, message_hash FROM voting.poll_chats WHERE poll_id = $1 ORDER BY id`,
+		pollID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения публикаций: %w", err)
+	}
+	defer rows.Close()
+
+	chats := make([]PollChat, 0)
+	for rows.Next() {
+		var chat PollChat
+		var chatID *int64
+		var messageID *int64
+		var inlineMessageID *string
+		if err := rows.Scan(&chat.ID, &chatID, &messageID, &inlineMessageID, &chat.MessageHash); err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных poll_chats: %w", err)
+		}
+		if chatID != nil {
+			chat.ChatID = *chatID
+		}
+		if messageID != nil {
+			chat.MessageID = *messageID
+		}
+		if inlineMessageID != nil {
+			chat.InlineMessageID = *inlineMessageID
+		}
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+// SetPollChatHash сохраняет хеш отрисованного сообщения
+func (s *PostgresStore) SetPollChatHash(ctx context.Context, pollChatID, hash int64) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE voting.poll_chats SET message_hash = $1 WHERE id = $2`,
+		hash, pollChatID)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения хеша: %w", err)
+	}
+	return nil
+}
+
+// GetStats считает общие счетчики одним запросом
+func (s *PostgresStore) GetStats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := s.db.QueryRow(ctx,
+		`SELECT
+		     (SELECT COUNT(*) FROM voting.polls),
+		     (SELECT COUNT(*) FROM voting.polls WHERE is_active = true AND (expires_at IS NULL OR expires_at > NOW())),
+		     (SELECT COUNT(DISTINCT creator_telegram_id) FROM voting.polls),
+		     (SELECT COUNT(*) FROM voting.votes),
+		     (SELECT COUNT(*) FROM voting.ballots WHERE rank = 1),
+		     (SELECT COUNT(*) FROM (SELECT user_telegram_id FROM voting.votes
+		                            UNION
+		                            SELECT user_telegram_id FROM voting.ballots) AS voters),
+		     (SELECT COUNT(*) FROM voting.poll_chats),
+		     (SELECT COUNT(*) FROM voting.vote_log),
+		     (SELECT COUNT(*) FROM voting.banned_users)`).
+		Scan(&stats.Polls, &stats.ActivePolls, &stats.Creators, &stats.Votes, &stats.Ballots,
+			&stats.Voters, &stats.Publications, &stats.VoteLog, &stats.BannedUsers)
+	if err != nil {
+		return Stats{}, fmt.Errorf("ошибка подсчета статистики: %w", err)
+	}
+	return stats, nil
+}
+
+// BanUser добавляет пользователя в voting.banned_users
+func (s *PostgresStore) BanUser(ctx context.Context, userID, adminID int64) error {
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO voting.banned_users (user_telegram_id, banned_by)
+		 VALUES ($1, $2)
+		 ON CONFLICT (user_telegram_id) DO NOTHING`,
+		userID, adminID)
+	if err != nil {
+		return fmt.Errorf("ошибка блокировки пользователя: %w", err)
+	}
+	return nil
+}
+
+// UnbanUser удаляет пользователя из voting.banned_users
+func (s *PostgresStore) UnbanUser(ctx context.Context, userID int64) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM voting.banned_users WHERE user_telegram_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка снятия блокировки: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errUserNotBanned
+	}
+	return nil
+}
+
+// ListBannedUsers читает voting.banned_users
+func (s *PostgresStore) ListBannedUsers(ctx context.Context) ([]int64, error) {
+	rows, err := s.db.Query(ctx, `SELECT user_telegram_id FROM voting.banned_users ORDER BY user_telegram_id`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заблокированных пользователей: %w", err)
+	}
+	users, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения заблокированных пользователей: %w", err)
+	}
+	return users, nil
+}
+
+// FindUserID ищет пользователя по username среди авторов, голосов и бюллетеней
+func (s *PostgresStore) FindUserID(ctx context.Context, username string) (int64, error) {
+	var userID int64
+	err := s.db.QueryRow(ctx,
+		`SELECT user_id FROM (
+		     SELECT creator_telegram_id AS user_id, created_at AS seen_at FROM voting.polls WHERE lower(creator_username) = lower($1)
+		     UNION ALL
+		     SELECT user_telegram_id, voted_at FROM voting.votes WHERE lower(user_username) = lower($1)
+		     UNION ALL
+		     SELECT user_telegram_id, submitted_at FROM voting.ballots WHERE lower(user_username) = lower($1)
+		 ) AS users
+		 ORDER BY seen_at DESC
+		 LIMIT 1`,
+		username).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errUserNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка поиска пользователя: %w", err)
+	}
+	return userID, nil
+}
+
+// escapeLikePattern экранирует спецсимволы LIKE/ILIKE, чтобы текст запроса искался буквально
+func escapeLikePattern(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
@@ -1,30 +1,74 @@
 package bot
 
 import (
-	"log"
+	"log/slog"
 	"sync"
+	"time"
 )
 
 // UpdateQueue управляет очередью задач на обновление сообщений голосований.
 // Задачи дедуплицируются по pollID и обрабатываются последовательно в одном потоке.
+// Одно голосование перерисовывается не чаще minInterval: запросы, пришедшие раньше,
+// сливаются в одну отложенную перерисовку в конце интервала, поэтому последнее
+// состояние голосования всегда попадает в сообщения.
 type UpdateQueue struct {
-	mu      sync.Mutex
-	pending map[int64]struct{} // множество pollID, ожидающих обновления
-	notify  chan struct{}      // сигнальный канал для пробуждения воркера
+	mu          sync.Mutex
+	pending     map[int64]struct{}  // множество pollID, ожидающих обновления
+	delayed     map[int64]time.Time // pollID -> время отложенного обновления (интервал, 429 или исчерпание бюджета)
+	lastRender  map[int64]time.Time // pollID -> время, когда воркер последний раз забрал голосование
+	minInterval time.Duration
+	notify      chan struct{}    // сигнальный канал для пробуждения воркера
+	closed      bool             // очередь закрыта при остановке бота, новые задачи не принимаются
+	now         func() time.Time // подменяется в тестах
+	log         *slog.Logger
+
+	// Счетчики для мониторинга (с момента запуска)
+	scheduled uint64 // запросов на перерисовку
+	coalesced uint64 // запросов, слитых с уже ожидающей перерисовкой
+	rendered  uint64 // голосований, отданных воркеру
+	dropped   uint64 // запросов, пришедших после закрытия очереди
+}
+
+// UpdateQueueStats состояние очереди обновлений для мониторинга
+type UpdateQueueStats struct {
+	Pending   int    // голосований ждут воркера
+	Delayed   int    // голосований отложено до конца интервала или паузы Telegram
+	Scheduled uint64 // всего запросов на перерисовку
+	Coalesced uint64 // запросов, слитых с уже ожидающей перерисовкой
+	Rendered  uint64 // перерисовок, отданных воркеру
+	Dropped   uint64 // запросов, отброшенных после остановки бота (сообщения остались со старыми итогами)
 }
 
-// NewUpdateQueue создает новую очередь обновлений
-func NewUpdateQueue() *UpdateQueue {
+// NewUpdateQueue создает новую очередь обновлений.
+// minInterval — минимальный интервал между перерисовками одного голосования (0 — без ограничения).
+func NewUpdateQueue(minInterval time.Duration) *UpdateQueue {
 	return &UpdateQueue{
-		pending: make(map[int64]struct{}),
-		notify:  make(chan struct{}, 1),
+		pending:     make(map[int64]struct{}),
+		delayed:     make(map[int64]time.Time),
+		lastRender:  make(map[int64]time.Time),
+		minInterval: minInterval,
+		notify:      make(chan struct{}, 1),
+		now:         time.Now,
+		log:         slog.Default().With("component", "UpdateQueue"),
 	}
 }
 
 // Schedule добавляет pollID в очередь на обновление.
-// Если pollID уже в очереди, повторно не добавляется (дедупликация).
+// Если pollID уже в очереди или отложен, запрос сливается с ожидающей перерисовкой.
 func (q *UpdateQueue) Schedule(pollID int64) {
 	q.mu.Lock()
+	if q.closed {
+		q.dropped++
+		q.mu.Unlock()
+		q.log.Warn("queue closed, render dropped", "poll_id", pollID)
+		return
+	}
+	q.scheduled++
+	_, isPending := q.pending[pollID]
+	_, isDelayed := q.delayed[pollID]
+	if isPending || isDelayed {
+		q.coalesced++
+	}
 	q.pending[pollID] = struct{}{}
 	q.mu.Unlock()
 
@@ -35,18 +79,113 @@ func (q *UpdateQueue) Schedule(pollID int64) {
 		// Воркер уже уведомлён, новое уведомление не нужно
 	}
 
-	log.Printf("📨 [UpdateQueue] Задача на обновление голосования %d добавлена в очередь", pollID)
+	q.log.Debug("render scheduled", "poll_id", pollID)
+}
+
+// ScheduleAfter добавляет pollID в очередь через delay.
+// Если обновление уже отложено на более ранний срок, новый таймер не заводится.
+func (q *UpdateQueue) ScheduleAfter(pollID int64, delay time.Duration) {
+	q.mu.Lock()
+	armed := q.scheduleAfterLocked(pollID, delay)
+	q.mu.Unlock()
+
+	if armed {
+		q.log.Debug("render delayed", "poll_id", pollID, "delay", delay.Round(time.Millisecond))
+	}
+}
+
+// scheduleAfterLocked заводит таймер отложенного обновления (вызывается под mu).
+// Возвращает false, если обновление уже отложено на более ранний срок.
+func (q *UpdateQueue) scheduleAfterLocked(pollID int64, delay time.Duration) bool {
+	if q.closed {
+		q.dropped++
+		return false
+	}
+	at := q.now().Add(delay)
+	if existing, ok := q.delayed[pollID]; ok && !existing.After(at) {
+		return false
+	}
+	q.delayed[pollID] = at
+
+	time.AfterFunc(delay, func() {
+		q.mu.Lock()
+		if q.closed {
+			// Отложенное обновление уже забрал close
+			q.mu.Unlock()
+			return
+		}
+		if q.delayed[pollID] == at {
+			delete(q.delayed, pollID)
+		}
+		q.mu.Unlock()
+		q.Schedule(pollID)
+	})
+	return true
 }
 
-// drain забирает все ожидающие pollID и очищает очередь
+// drain забирает ожидающие pollID, которые можно перерисовать сейчас, и очищает очередь.
+// Голосования, перерисованные меньше minInterval назад, откладываются до конца интервала.
 func (q *UpdateQueue) drain() []int64 {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	now := q.now()
 	polls := make([]int64, 0, len(q.pending))
 	for pollID := range q.pending {
+		if last, ok := q.lastRender[pollID]; ok {
+			if wait := last.Add(q.minInterval).Sub(now); wait > 0 {
+				q.scheduleAfterLocked(pollID, wait)
+				continue
+			}
+		}
 		polls = append(polls, pollID)
+		q.lastRender[pollID] = now
 	}
 	q.pending = make(map[int64]struct{})
+	q.rendered += uint64(len(polls))
+
+	// Время перерисовки нужно только в пределах интервала
+	for pollID, last := range q.lastRender {
+		if now.Sub(last) >= q.minInterval {
+			delete(q.lastRender, pollID)
+		}
+	}
 	return polls
 }
+
+// close закрывает очередь и возвращает все ожидающие и отложенные pollID
+// без учета интервала: при остановке бота их нужно перерисовать сразу
+func (q *UpdateQueue) close() []int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	polls := make([]int64, 0, len(q.pending)+len(q.delayed))
+	for pollID := range q.pending {
+		polls = append(polls, pollID)
+	}
+	for pollID := range q.delayed {
+		if _, ok := q.pending[pollID]; !ok {
+			polls = append(polls, pollID)
+		}
+	}
+	q.pending = make(map[int64]struct{})
+	q.delayed = make(map[int64]time.Time)
+	q.rendered += uint64(len(polls))
+	return polls
+}
+
+// Stats возвращает глубину очереди и счетчики слияния запросов
+func (q *UpdateQueue) Stats() UpdateQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return UpdateQueueStats{
+		Pending:   len(q.pending),
+		Delayed:   len(q.delayed),
+		Scheduled: q.scheduled,
+		Coalesced: q.coalesced,
+		Rendered:  q.rendered,
+		Dropped:   q.dropped,
+	}
+}
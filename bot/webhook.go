@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"wubrg-voting-bot/config"
+
+	"gopkg.in/telebot.v4"
+)
+
+// webhookSecretHeader заголовок, в котором Telegram передает секретный токен webhook
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookShutdownTimeout сколько HTTP-сервер webhook ждет завершения запросов при остановке
+const webhookShutdownTimeout = 5 * time.Second
+
+// webhookPoller принимает обновления от Telegram HTTP-запросами.
+// В отличие от telebot.Webhook отвечает 401 на неверный секрет и 400 на некорректный JSON
+// и сообщает, поднят ли HTTP-сервер.
+type webhookPoller struct {
+	cfg config.Webhook
+	ln  net.Listener // открытый порт; привязывается в listen до запуска поллера
+	log *slog.Logger
+
+	mu   sync.Mutex
+	addr net.Addr // адрес, на котором слушает сервер (nil — не запущен)
+}
+
+// newWebhookPoller создает поллер по проверенным настройкам
+func newWebhookPoller(cfg config.Webhook) *webhookPoller {
+	return &webhookPoller{cfg: cfg, log: slog.Default().With("component", "Webhook")}
+}
+
+// listen открывает порт webhook. Вызывается при создании бота, чтобы занятый порт
+// или неверный адрес были ошибкой запуска, а не записью в логе работающего бота.
+func (p *webhookPoller) listen() error {
+	ln, err := net.Listen("tcp", p.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть порт webhook %s: %w", p.cfg.Listen, err)
+	}
+	p.ln = ln
+	return nil
+}
+
+// register регистрирует публичный адрес webhook в Telegram
+func (p *webhookPoller) register(tgBot *telebot.Bot) error {
+	if p.cfg.PublicURL == "" {
+		p.log.Warn("public url not set, webhook is not registered in telegram")
+		return nil
+	}
+	err := tgBot.SetWebhook(&telebot.Webhook{
+		SecretToken: p.cfg.SecretToken,
+		Endpoint:    &telebot.WebhookEndpoint{PublicURL: p.cfg.PublicURL},
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось зарегистрировать webhook: %w", err)
+	}
+	p.log.Info("webhook registered", "url", p.cfg.PublicURL)
+	return nil
+}
+
+// Poll обслуживает HTTP-запросы на порту, открытом в listen, и передает полученные
+// обновления боту до закрытия stop
+func (p *webhookPoller) Poll(_ *telebot.Bot, dest chan telebot.Update, stop chan struct{}) {
+	ln := p.ln
+
+	server := &http.Server{
+		Handler:           p.handler(dest),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	p.setAddr(ln.Addr())
+	defer p.setAddr(nil)
+
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			p.log.Error("failed to shut down http server", "error", err)
+		}
+	}()
+
+	p.log.Info("receiving updates", "addr", ln.Addr().String(), "tls", p.cfg.TLSCert != "")
+	var err error
+	if p.cfg.TLSCert != "" {
+		err = server.ServeTLS(ln, p.cfg.TLSCert, p.cfg.TLSKey)
+	} else {
+		err = server.Serve(ln)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		p.log.Error("http server failed", "error", err)
+		<-stop
+	}
+}
+
+// handler принимает POST с JSON-обновлением Telegram
+func (p *webhookPoller) handler(dest chan<- telebot.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if p.cfg.SecretToken != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(p.cfg.SecretToken)) != 1 {
+			p.log.Warn("request with invalid secret token", "remote_addr", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var update telebot.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			p.log.Warn("malformed update", "remote_addr", r.RemoteAddr, "error", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case dest <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// setAddr запоминает адрес запущенного сервера
+func (p *webhookPoller) setAddr(addr net.Addr) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.addr = addr
+}
+
+// listenAddr возвращает адрес, на котором слушает сервер (nil — не запущен)
+func (p *webhookPoller) listenAddr() net.Addr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.addr
+}
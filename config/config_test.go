@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envLookup подменяет os.LookupEnv набором переменных
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestReadAppliesFileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := `{
+		"bot_token": "from-file",
+		"log_level": "debug",
+		"render_interval": "500ms",
+		"inline": {"cache_time": 60},
+		"limits": {"title_max_length": 120, "list_polls": 20},
+		"rate_limit": {"vote_user_burst": 3, "edit_max_wait": "250ms"}
+	}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := read(path, envLookup(map[string]string{
+		"BOT_TOKEN":            "from-env",
+		"LOG_LEVEL":            "WARN",
+		"CACHE_TIME":           "0",
+		"INLINE_PAGE_SIZE":     "",
+		"EDIT_CHAT_PER_MINUTE": "12.5",
+		"ADMIN_IDS":            "101, 202",
+	}))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if cfg.BotToken != "from-env" || cfg.LogLevel != LogLevelWarn {
+		t.Errorf("переменные окружения должны переопределять файл: %+v", cfg)
+	}
+	if time.Duration(cfg.RenderInterval) != 500*time.Millisecond || cfg.Limits.TitleMaxLength != 120 || cfg.Limits.ListPolls != 20 {
+		t.Errorf("значения из файла не применены: %+v", cfg)
+	}
+	r := cfg.RateLimit
+	if r.VoteUserBurst != 3 || time.Duration(r.EditMaxWait) != 250*time.Millisecond || r.EditChatPerMinute != 12.5 {
+		t.Errorf("лимиты частоты из файла и окружения не применены: %+v", r)
+	}
+	if r.VotePollBurst != Default().RateLimit.VotePollBurst {
+		t.Errorf("незаданный лимит должен остаться по умолчанию: %+v", r)
+	}
+	if cfg.Inline.CacheTime != 0 {
+		t.Errorf("CACHE_TIME=0 должен отключать кеширование: %d", cfg.Inline.CacheTime)
+	}
+	if len(cfg.Admins) != 2 || cfg.Admins[0] != 101 || cfg.Admins[1] != 202 {
+		t.Errorf("ADMIN_IDS разобран неверно: %v", cfg.Admins)
+	}
+	if cfg.Inline.PageSize != Default().Inline.PageSize || cfg.Limits.OptionMaxLength != Default().Limits.OptionMaxLength {
+		t.Errorf("незаданные значения должны остаться по умолчанию: %+v", cfg)
+	}
+}
+
+func TestReadRejectsMalformedInput(t *testing.T) {
+	if _, err := read("", envLookup(map[string]string{"CACHE_TIME": "ten"})); err == nil {
+		t.Error("нечисловой CACHE_TIME принят")
+	}
+	if _, err := read("", envLookup(map[string]string{"RENDER_INTERVAL": "2"})); err == nil {
+		t.Error("RENDER_INTERVAL без единиц принят")
+	}
+	if _, err := read("", envLookup(map[string]string{"VOTE_USER_RATE": "fast"})); err == nil {
+		t.Error("нечисловой VOTE_USER_RATE принят")
+	}
+	if _, err := read("", envLookup(map[string]string{"ADMIN_IDS": "101;@admin"})); err == nil {
+		t.Error("нечисловой ADMIN_IDS принят")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"cache_tiem": 5}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read(path, envLookup(nil)); err == nil {
+		t.Error("опечатка в имени поля файла настроек не обнаружена")
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := Default()
+	cfg.LogLevel = "verbose"
+	cfg.Inline.PageSize = 100
+	cfg.Limits.TitleMaxLength = 1
+	cfg.Limits.HistoryPageSize = 0
+	cfg.RateLimit.EditChatPerMinute = 0
+	cfg.ExpiryInterval = 0
+	cfg.BanRefreshInterval = 0
+	cfg.Admins = []int64{101, -5}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("ожидалась ошибка")
+	}
+	for _, want := range []string{"BOT_TOKEN", "LOG_LEVEL", "INLINE_PAGE_SIZE", "POLL_TITLE_MAX_LENGTH", "HISTORY_PAGE_SIZE", "EDIT_CHAT_PER_MINUTE", "EXPIRY_INTERVAL", "BAN_REFRESH_INTERVAL", "ADMIN_IDS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет %s: %v", want, err)
+		}
+	}
+}
+
+func TestLoggerLevelAndFormat(t *testing.T) {
+	cfg := Default()
+	cfg.LogLevel = LogLevelWarn
+	cfg.LogFormat = LogFormatJSON
+
+	var buf bytes.Buffer
+	logger := cfg.Logger(&buf)
+	logger.Info("skipped")
+	logger.Warn("render failed", "poll_id", 42)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("ожидалась одна запись уровня warn: %q", buf.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("запись не в формате JSON: %v", err)
+	}
+	if record["msg"] != "render failed" || record["poll_id"] != float64(42) {
+		t.Errorf("поля записи: %v", record)
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	valid := Webhook{Listen: ":8443", PublicURL: "https://bot.example.com/telegram", SecretToken: "abc_DEF-123"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("корректные настройки отклонены: %v", err)
+	}
+	if err := (Webhook{Listen: ":8080"}).Validate(); err != nil {
+		t.Errorf("локальный режим без публичного адреса отклонен: %v", err)
+	}
+	if err := (Webhook{}).Validate(); err != nil {
+		t.Errorf("выключенный webhook отклонен: %v", err)
+	}
+
+	invalid := map[string]Webhook{
+		"без адреса":           {PublicURL: "https://bot.example.com"},
+		"http вместо https":    {Listen: ":8080", PublicURL: "http://bot.example.com"},
+		"недопустимый токен":   {Listen: ":8080", SecretToken: "секрет"},
+		"сертификат без ключа": {Listen: ":8443", TLSCert: "cert.pem"},
+	}
+	for name, w := range invalid {
+		if err := w.Validate(); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 -- Удаление всех таблиц (для полного пересоздания схемы)
 -- ВНИМАНИЕ: Это удалит все данные!
 
+DROP TABLE IF EXISTS voting.banned_users CASCADE;
+DROP TABLE IF EXISTS voting.dialog_sessions CASCADE;
+DROP TABLE IF EXISTS voting.ballots CASCADE;
 DROP TABLE IF EXISTS voting.votes CASCADE;
 DROP TABLE IF EXISTS voting.poll_chats CASCADE;
 DROP TABLE IF EXISTS voting.poll_options CASCADE;
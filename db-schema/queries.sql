@@ -174,6 +174,7 @@ ORDER BY polls_count DESC;
 -- ================================================
 
 -- Закрыть голосование (сделать неактивным)
+-- В боте: /closepoll <ID> (вернуть — /reopenpoll <ID>); при ручном закрытии сообщения не перерисуются
 -- UPDATE voting.polls SET is_active = false, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
 
 -- Удалить голос пользователя
@@ -189,7 +190,7 @@ ORDER BY polls_count DESC;
 -- Удалить неактивные голосования старше 30 дней
 -- DELETE FROM voting.polls WHERE is_active = false AND created_at < NOW() - INTERVAL '30 days';
 
--- Закрыть истекшие голосования
+-- Закрыть истекшие голосования (бот делает это сам, см. bot/expiry.go)
 -- UPDATE voting.polls SET is_active = false, updated_at = CURRENT_TIMESTAMP 
 -- WHERE is_active = true AND expires_at < CURRENT_TIMESTAMP;
 
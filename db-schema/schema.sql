@@ -1,8 +1,16 @@
 -- Схема данных для бота голосований WUBRG
+--
+-- УСТАРЕЛО: файл больше не обновляется и не нужен для установки.
+-- Схему создает и обновляет бот при запуске (или `wubrg-voting-bot migrate up`)
+-- по версионным миграциям из migrations/sql — это единственный источник схемы.
+-- Здесь сохранен снимок схемы на версии миграций 0006 для справки.
 
 -- Создание кастомной схемы
 CREATE SCHEMA IF NOT EXISTS voting;
 
+-- Расширение для триграммного поиска (inline-поиск по названию и описанию)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- Таблица голосований
 CREATE TABLE IF NOT EXISTS voting.polls (
     id BIGSERIAL PRIMARY KEY,
@@ -13,13 +21,20 @@ CREATE TABLE IF NOT EXISTS voting.polls (
     is_active BOOLEAN DEFAULT true,                    -- Активно ли голосование
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),     -- Дата создания
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),     -- Дата последнего обновления
-    expires_at TIMESTAMPTZ                             -- Дата окончания голосования (опционально)
+    expires_at TIMESTAMPTZ,                            -- Дата окончания голосования (опционально)
+    allow_multiple BOOLEAN NOT NULL DEFAULT false,     -- Можно ли выбрать несколько вариантов
+    max_selections INTEGER CHECK (max_selections IS NULL OR max_selections > 0), -- Лимит выбранных вариантов (NULL — без ограничений)
+    is_anonymous BOOLEAN NOT NULL DEFAULT false,       -- Анонимное голосование (имена не показываются)
+    vote_type TEXT NOT NULL DEFAULT 'plurality'        -- Тип голосования: plurality или ranked (IRV)
+        CHECK (vote_type IN ('plurality', 'ranked'))
 );
 
 -- Индексы для таблицы polls
 CREATE INDEX IF NOT EXISTS idx_polls_creator ON voting.polls(creator_telegram_id);
 CREATE INDEX IF NOT EXISTS idx_polls_is_active ON voting.polls(is_active);
 CREATE INDEX IF NOT EXISTS idx_polls_created_at ON voting.polls(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_polls_title_trgm ON voting.polls USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_polls_description_trgm ON voting.polls USING GIN (description gin_trgm_ops) WHERE description IS NOT NULL;
 
 -- Таблица вариантов ответов
 CREATE TABLE IF NOT EXISTS voting.poll_options (
@@ -68,13 +83,82 @@ CREATE TABLE IF NOT EXISTS voting.votes (
     user_first_name TEXT,                                                     -- Имя пользователя
     user_last_name TEXT,                                                      -- Фамилия пользователя (опционально)
     voted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),                              -- Дата и время голоса
-    CONSTRAINT unique_vote_per_user_option UNIQUE (poll_id, user_telegram_id)
+    CONSTRAINT unique_vote_per_user_poll_option UNIQUE (poll_id, user_telegram_id, option_id)
 );
 
 -- Индексы для таблицы votes
 CREATE INDEX IF NOT EXISTS idx_votes_poll_id ON voting.votes(poll_id);
 CREATE INDEX IF NOT EXISTS idx_votes_option_id ON voting.votes(option_id);
 
+-- Бюллетени рейтинговых голосований (одна строка на ранжированный вариант)
+CREATE TABLE IF NOT EXISTS voting.ballots (
+    id BIGSERIAL PRIMARY KEY,
+    poll_id BIGINT NOT NULL REFERENCES voting.polls(id) ON DELETE CASCADE,           -- ID голосования
+    option_id BIGINT NOT NULL REFERENCES voting.poll_options(id) ON DELETE CASCADE,  -- ID варианта
+    rank INTEGER NOT NULL CHECK (rank > 0),                                          -- Место варианта (1 — самый предпочтительный)
+    user_telegram_id BIGINT NOT NULL,                                                -- Telegram ID участника
+    user_username TEXT,                                                              -- Username участника (опционально)
+    user_first_name TEXT,                                                            -- Имя участника
+    user_last_name TEXT,                                                             -- Фамилия участника (опционально)
+    submitted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),                                 -- Время отправки бюллетеня
+    CONSTRAINT unique_ballot_rank UNIQUE (poll_id, user_telegram_id, rank),
+    CONSTRAINT unique_ballot_option UNIQUE (poll_id, user_telegram_id, option_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ballots_poll_id ON voting.ballots(poll_id);
+
+-- Анонимное голосование с голосами нельзя сделать открытым
+CREATE OR REPLACE FUNCTION voting.forbid_deanonymize_poll() RETURNS trigger AS $$
+BEGIN
+    IF OLD.is_anonymous AND NOT NEW.is_anonymous
+       AND EXISTS (SELECT 1 FROM voting.votes WHERE poll_id = OLD.id) THEN
+        RAISE EXCEPTION 'poll % is anonymous and already has votes', OLD.id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_polls_forbid_deanonymize ON voting.polls;
+CREATE TRIGGER trg_polls_forbid_deanonymize
+    BEFORE UPDATE OF is_anonymous ON voting.polls
+    FOR EACH ROW EXECUTE FUNCTION voting.forbid_deanonymize_poll();
+
+-- Один голос на пользователя в голосовании без множественного выбора.
+-- UNIQUE (poll_id, user_telegram_id, option_id) этого не гарантирует, поэтому вставку
+-- второго варианта отклоняет триггер. Он берет ту же advisory-блокировку, что и бот,
+-- так что параллельные вставки одного пользователя видят друг друга
+CREATE OR REPLACE FUNCTION voting.enforce_single_choice_vote() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM voting.polls WHERE id = NEW.poll_id AND NOT allow_multiple) THEN
+        PERFORM pg_advisory_xact_lock(hashtextextended('vote:' || NEW.poll_id::text || ':' || NEW.user_telegram_id::text, 0));
+        IF EXISTS (SELECT 1 FROM voting.votes
+                   WHERE poll_id = NEW.poll_id AND user_telegram_id = NEW.user_telegram_id
+                     AND option_id != NEW.option_id AND id != NEW.id) THEN
+            RAISE EXCEPTION 'user % already voted in single-choice poll %', NEW.user_telegram_id, NEW.poll_id
+                USING ERRCODE = 'unique_violation';
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_votes_single_choice ON voting.votes;
+CREATE TRIGGER trg_votes_single_choice
+    BEFORE INSERT OR UPDATE OF poll_id, option_id, user_telegram_id ON voting.votes
+    FOR EACH ROW EXECUTE FUNCTION voting.enforce_single_choice_vote();
+
+-- Сессии диалогов пользователей с ботом
+CREATE TABLE IF NOT EXISTS voting.dialog_sessions (
+    user_telegram_id BIGINT PRIMARY KEY,          -- Telegram ID пользователя
+    state TEXT NOT NULL,                          -- Состояние диалога
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,      -- Данные диалога (черновик голосования и т.д.)
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- Время последнего изменения
+    expires_at TIMESTAMPTZ NOT NULL               -- Время истечения сессии
+);
+
+CREATE INDEX IF NOT EXISTS idx_dialog_sessions_expires_at ON voting.dialog_sessions(expires_at);
+
 -- Таблица логирования всех нажатий на кнопки (append-only)
 CREATE TABLE IF NOT EXISTS voting.vote_log (
     id BIGSERIAL PRIMARY KEY,
@@ -91,5 +175,7 @@ COMMENT ON TABLE voting.poll_chats IS 'Чаты и inline-сообщения, к
 COMMENT ON COLUMN voting.poll_chats.inline_message_id IS 'ID inline-сообщения (если голосование отправлено через inline-режим)';
 COMMENT ON COLUMN voting.poll_chats.message_hash IS 'Хеш для дополнительной идентификации сообщения';
 COMMENT ON TABLE voting.votes IS 'Голоса пользователей';
+COMMENT ON TABLE voting.ballots IS 'Бюллетени рейтинговых голосований (одна строка на ранжированный вариант)';
+COMMENT ON TABLE voting.dialog_sessions IS 'Сессии диалогов пользователей с ботом (TTL по expires_at)';
 COMMENT ON TABLE voting.vote_log IS 'Лог всех нажатий на кнопки голосования (append-only, без индексов)';
 
@@ -0,0 +1,336 @@
+// Package migrations содержит версионные миграции схемы voting, встроенные в бинарник.
+//
+// Каждая миграция — пара файлов sql/NNNN_name.up.sql и sql/NNNN_name.down.sql.
+// Примененные версии хранятся в таблице voting.schema_migrations; каждая миграция
+// выполняется в отдельной транзакции вместе с записью о ней.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey ключ advisory-блокировки: миграции не применяются параллельно несколькими экземплярами бота
+const lockKey = "wubrg-voting-bot:schema_migrations"
+
+// Migration одна версия схемы
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status состояние миграции в базе
+type Status struct {
+	Migration
+	AppliedAt *time.Time // nil — миграция не применена
+}
+
+// All возвращает все встроенные миграции по возрастанию версии
+func All() ([]Migration, error) {
+	return load(files)
+}
+
+// load читает пары up/down из файловой системы и проверяет, что версии идут подряд с 1
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("неожиданный файл миграции %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionText, migrationName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("имя файла миграции %s не соответствует NNNN_name", name)
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная версия в имени файла миграции %s", name)
+		}
+
+		content, err := fs.ReadFile(fsys, "sql/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения миграции %s: %w", name, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = m
+		}
+		if m.Name != migrationName {
+			return nil, fmt.Errorf("у версии %d разные имена: %s и %s", version, m.Name, migrationName)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("у миграции %04d_%s нет up- или down-файла", m.Version, m.Name)
+		}
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+
+	for i, m := range all {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("пропущена версия миграции %d", i+1)
+		}
+	}
+	return all, nil
+}
+
+// ensureTable создает схему voting и таблицу версий, если их еще нет
+func ensureTable(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx,
+		`CREATE SCHEMA IF NOT EXISTS voting;
+		 CREATE TABLE IF NOT EXISTS voting.schema_migrations (
+		     version INTEGER PRIMARY KEY,
+		     name TEXT NOT NULL,
+		     applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		 )`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// tableExists сообщает, создана ли таблица версий (только чтение, без DDL)
+func tableExists(ctx context.Context, db *pgxpool.Pool) (bool, error) {
+	var exists bool
+	err := db.QueryRow(ctx, `SELECT to_regclass('voting.schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("ошибка проверки таблицы schema_migrations: %w", err)
+	}
+	return exists, nil
+}
+
+// appliedVersions возвращает время применения каждой версии
+func appliedVersions(ctx context.Context, q interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}) (map[int]time.Time, error) {
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM voting.schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Statuses возвращает состояние всех встроенных миграций.
+// Только читает базу: если таблицы версий еще нет, ни одна миграция не применена.
+func Statuses(ctx context.Context, db *pgxpool.Pool) ([]Status, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	exists, err := tableExists(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	if exists {
+		if applied, err = appliedVersions(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(all))
+	for _, m := range all {
+		status := Status{Migration: m}
+		if appliedAt, ok := applied[m.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CheckApplied проверяет, что все встроенные миграции применены. Только читает базу:
+// если таблицы voting.schema_migrations нет, миграции еще не запускались, и возвращается
+// ошибка. Используется проверкой /readyz.
+func CheckApplied(ctx context.Context, db *pgxpool.Pool) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+	exists, err := tableExists(ctx, db)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("таблица voting.schema_migrations не найдена: миграции не применялись")
+	}
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+	if pending := unapplied(all, applied); len(pending) > 0 {
+		return fmt.Errorf("не применено миграций: %d (первая — %04d_%s)", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// unapplied возвращает миграции из all, которых нет среди примененных версий
+func unapplied(all []Migration, applied map[int]time.Time) []Migration {
+	pending := make([]Migration, 0)
+	for _, m := range all {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Up применяет все неприменённые миграции по порядку и возвращает примененные
+func Up(ctx context.Context, db *pgxpool.Pool) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, m := range all {
+		ok, err := apply(ctx, db, m)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// apply выполняет up-миграцию в транзакции, если она еще не применена
+func apply(ctx context.Context, db *pgxpool.Pool, m Migration) (bool, error) {
+	return inLockedTx(ctx, db, func(tx pgx.Tx, applied map[int]time.Time) (bool, error) {
+		if _, ok := applied[m.Version]; ok {
+			return false, nil
+		}
+		if _, err := tx.Exec(ctx, m.Up); err != nil {
+			return false, fmt.Errorf("ошибка применения миграции %04d_%s: %w", m.Version, m.Name, err)
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO voting.schema_migrations (version, name) VALUES ($1, $2)`,
+			m.Version, m.Name)
+		if err != nil {
+			return false, fmt.Errorf("ошибка записи версии %d: %w", m.Version, err)
+		}
+		return true, nil
+	})
+}
+
+// Down откатывает последнюю примененную миграцию (nil — откатывать нечего)
+func Down(ctx context.Context, db *pgxpool.Pool) (*Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	var reverted *Migration
+	_, err = inLockedTx(ctx, db, func(tx pgx.Tx, applied map[int]time.Time) (bool, error) {
+		for i := len(all) - 1; i >= 0; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if _, err := tx.Exec(ctx, m.Down); err != nil {
+				return false, fmt.Errorf("ошибка отката миграции %04d_%s: %w", m.Version, m.Name, err)
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM voting.schema_migrations WHERE version = $1`, m.Version)
+			if err != nil {
+				return false, fmt.Errorf("ошибка удаления версии %d: %w", m.Version, err)
+			}
+			reverted = &m
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// inLockedTx выполняет fn в транзакции под advisory-блокировкой миграций.
+// Список примененных версий читается уже под блокировкой, чтобы два экземпляра
+// бота, стартующие одновременно, не применили одну миграцию дважды.
+func inLockedTx(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx, applied map[int]time.Time) (bool, error)) (bool, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, lockKey); err != nil {
+		return false, fmt.Errorf("ошибка блокировки миграций: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := fn(tx, applied)
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("ошибка фиксации транзакции: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrationsAreComplete(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatal("нет встроенных миграций")
+	}
+	for _, m := range all {
+		// Транзакцией управляет Up/Down, собственные BEGIN/COMMIT в файлах ее бы завершили
+		for _, sql := range []string{m.Up, m.Down} {
+			for _, line := range strings.Split(sql, "\n") {
+				if stmt := strings.TrimSpace(line); stmt == "BEGIN;" || stmt == "COMMIT;" {
+					t.Errorf("миграция %04d_%s содержит %s", m.Version, m.Name, stmt)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"нет down-файла": {
+			"sql/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"пропущена версия": {
+			"sql/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"sql/0001_a.down.sql": {Data: []byte("SELECT 1;")},
+			"sql/0003_c.up.sql":   {Data: []byte("SELECT 1;")},
+			"sql/0003_c.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"разные имена у версии": {
+			"sql/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"sql/0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"лишний файл": {
+			"sql/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"sql/0001_a.down.sql": {Data: []byte("SELECT 1;")},
+			"sql/README.md":       {Data: []byte("")},
+		},
+	}
+	for name, fsys := range tests {
+		if _, err := load(fsys); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestUnappliedKeepsOrder(t *testing.T) {
+	all := []Migration{{Version: 1, Name: "a"}, {Version: 2, Name: "b"}, {Version: 3, Name: "c"}}
+	applied := map[int]time.Time{1: time.Now(), 3: time.Now()}
+
+	pending := unapplied(all, applied)
+	if len(pending) != 1 || pending[0].Version != 2 {
+		t.Errorf("не применена только версия 2: %+v", pending)
+	}
+	if pending := unapplied(all, map[int]time.Time{}); len(pending) != 3 {
+		t.Errorf("без примененных версий ожидаются все миграции: %+v", pending)
+	}
+}
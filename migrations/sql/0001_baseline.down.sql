@@ -0,0 +1,8 @@
+-- Откат миграции 0001: удаление базовой схемы
+-- ВНИМАНИЕ: удаляет все голосования, голоса и лог нажатий!
+
+DROP TABLE IF EXISTS voting.vote_log;
+DROP TABLE IF EXISTS voting.votes;
+DROP TABLE IF EXISTS voting.poll_chats;
+DROP TABLE IF EXISTS voting.poll_options;
+DROP TABLE IF EXISTS voting.polls;
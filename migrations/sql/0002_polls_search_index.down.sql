@@ -0,0 +1,5 @@
+-- Откат миграции 0002: триграммные индексы поиска
+-- Расширение pg_trgm не удаляется: им могут пользоваться другие схемы
+
+DROP INDEX IF EXISTS voting.idx_polls_description_trgm;
+DROP INDEX IF EXISTS voting.idx_polls_title_trgm;
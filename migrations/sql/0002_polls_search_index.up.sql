@@ -0,0 +1,11 @@
+-- Миграция 0002: триграммные индексы для поиска голосований в inline-режиме
+-- Поиск `@bot_name текст` выполняется через ILIKE по title и description,
+-- индексы gin_trgm_ops позволяют не сканировать всю таблицу
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_polls_title_trgm
+    ON voting.polls USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_polls_description_trgm
+    ON voting.polls USING GIN (description gin_trgm_ops)
+    WHERE description IS NOT NULL;
@@ -0,0 +1,21 @@
+-- Откат миграции 0003: множественный выбор
+-- ВНИМАНИЕ: прежнее ограничение UNIQUE (poll_id, user_telegram_id) нельзя вернуть, пока
+-- у пользователя несколько голосов в одном голосовании. Поэтому в голосованиях
+-- с множественным выбором у каждого пользователя остается только самый ранний голос,
+-- остальные удаляются безвозвратно.
+
+DELETE FROM voting.votes
+WHERE id IN (
+    SELECT id
+    FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY poll_id, user_telegram_id ORDER BY voted_at, id) AS n
+        FROM voting.votes
+    ) ranked
+    WHERE n > 1
+);
+
+ALTER TABLE voting.votes DROP CONSTRAINT IF EXISTS unique_vote_per_user_poll_option;
+ALTER TABLE voting.votes ADD CONSTRAINT unique_vote_per_user_option UNIQUE (poll_id, user_telegram_id);
+
+ALTER TABLE voting.polls DROP COLUMN IF EXISTS max_selections;
+ALTER TABLE voting.polls DROP COLUMN IF EXISTS allow_multiple;
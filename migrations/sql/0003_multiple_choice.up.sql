@@ -0,0 +1,14 @@
+-- Миграция 0003: голосования с выбором нескольких вариантов
+
+-- 1. Режим голосования и ограничение на число выбранных вариантов
+ALTER TABLE voting.polls ADD COLUMN IF NOT EXISTS allow_multiple BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE voting.polls ADD COLUMN IF NOT EXISTS max_selections INTEGER CHECK (max_selections IS NULL OR max_selections > 0);
+
+-- 2. Пользователь может иметь несколько голосов в голосовании, но не больше одного за каждый вариант.
+--    Один голос на пользователя в обычных голосованиях проверяет триггер trg_votes_single_choice (миграция 0004)
+ALTER TABLE voting.votes DROP CONSTRAINT IF EXISTS unique_vote_per_user_option;
+ALTER TABLE voting.votes DROP CONSTRAINT IF EXISTS unique_vote_per_user_poll_option;
+ALTER TABLE voting.votes ADD CONSTRAINT unique_vote_per_user_poll_option UNIQUE (poll_id, user_telegram_id, option_id);
+
+COMMENT ON COLUMN voting.polls.allow_multiple IS 'Можно ли выбрать несколько вариантов';
+COMMENT ON COLUMN voting.polls.max_selections IS 'Максимум выбранных вариантов при множественном выборе (NULL — без ограничений)';
@@ -0,0 +1,7 @@
+-- Откат миграции 0004: анонимные голосования
+
+DROP TRIGGER IF EXISTS trg_votes_single_choice ON voting.votes;
+DROP FUNCTION IF EXISTS voting.enforce_single_choice_vote();
+DROP TRIGGER IF EXISTS trg_polls_forbid_deanonymize ON voting.polls;
+DROP FUNCTION IF EXISTS voting.forbid_deanonymize_poll();
+ALTER TABLE voting.polls DROP COLUMN IF EXISTS is_anonymous;
@@ -0,0 +1,49 @@
+-- Миграция 0004: анонимные голосования
+-- В анонимном голосовании бот показывает только количество голосов и проценты.
+-- voting.votes по-прежнему хранит user_telegram_id, чтобы один пользователь не голосовал дважды
+
+ALTER TABLE voting.polls ADD COLUMN IF NOT EXISTS is_anonymous BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN voting.polls.is_anonymous IS 'Анонимное голосование: имена проголосовавших не показываются';
+
+-- Запрещаем делать анонимное голосование открытым, если в нем уже есть голоса:
+-- участники голосовали, рассчитывая на анонимность
+CREATE OR REPLACE FUNCTION voting.forbid_deanonymize_poll() RETURNS trigger AS $$
+BEGIN
+    IF OLD.is_anonymous AND NOT NEW.is_anonymous
+       AND EXISTS (SELECT 1 FROM voting.votes WHERE poll_id = OLD.id) THEN
+        RAISE EXCEPTION 'poll % is anonymous and already has votes', OLD.id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_polls_forbid_deanonymize ON voting.polls;
+CREATE TRIGGER trg_polls_forbid_deanonymize
+    BEFORE UPDATE OF is_anonymous ON voting.polls
+    FOR EACH ROW EXECUTE FUNCTION voting.forbid_deanonymize_poll();
+
+-- Один голос на пользователя в голосовании без множественного выбора.
+-- UNIQUE (poll_id, user_telegram_id, option_id) этого не гарантирует, поэтому вставку
+-- второго варианта отклоняет триггер. Он берет ту же advisory-блокировку, что и бот,
+-- так что параллельные вставки одного пользователя видят друг друга
+CREATE OR REPLACE FUNCTION voting.enforce_single_choice_vote() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM voting.polls WHERE id = NEW.poll_id AND NOT allow_multiple) THEN
+        PERFORM pg_advisory_xact_lock(hashtextextended('vote:' || NEW.poll_id::text || ':' || NEW.user_telegram_id::text, 0));
+        IF EXISTS (SELECT 1 FROM voting.votes
+                   WHERE poll_id = NEW.poll_id AND user_telegram_id = NEW.user_telegram_id
+                     AND option_id != NEW.option_id AND id != NEW.id) THEN
+            RAISE EXCEPTION 'user % already voted in single-choice poll %', NEW.user_telegram_id, NEW.poll_id
+                USING ERRCODE = 'unique_violation';
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_votes_single_choice ON voting.votes;
+CREATE TRIGGER trg_votes_single_choice
+    BEFORE INSERT OR UPDATE OF poll_id, option_id, user_telegram_id ON voting.votes
+    FOR EACH ROW EXECUTE FUNCTION voting.enforce_single_choice_vote();
@@ -0,0 +1,6 @@
+-- Откат миграции 0005: рейтинговые голосования
+-- Рейтинговые голосования удаляются вместе с бюллетенями
+
+DROP TABLE IF EXISTS voting.ballots;
+DELETE FROM voting.polls WHERE vote_type = 'ranked';
+ALTER TABLE voting.polls DROP COLUMN IF EXISTS vote_type;
@@ -0,0 +1,26 @@
+-- Миграция 0005: рейтинговые голосования (мгновенный второй тур, IRV)
+-- Участник расставляет варианты по порядку предпочтения в личном чате с ботом.
+-- Бюллетень хранится построчно: одна строка на каждый ранжированный вариант
+
+ALTER TABLE voting.polls ADD COLUMN IF NOT EXISTS vote_type TEXT NOT NULL DEFAULT 'plurality'
+    CHECK (vote_type IN ('plurality', 'ranked'));
+
+COMMENT ON COLUMN voting.polls.vote_type IS 'Тип голосования: plurality (обычное) или ranked (рейтинговое, IRV)';
+
+CREATE TABLE IF NOT EXISTS voting.ballots (
+    id BIGSERIAL PRIMARY KEY,
+    poll_id BIGINT NOT NULL REFERENCES voting.polls(id) ON DELETE CASCADE,           -- ID голосования
+    option_id BIGINT NOT NULL REFERENCES voting.poll_options(id) ON DELETE CASCADE,  -- ID варианта
+    rank INTEGER NOT NULL CHECK (rank > 0),                                          -- Место варианта (1 — самый предпочтительный)
+    user_telegram_id BIGINT NOT NULL,                                                -- Telegram ID участника
+    user_username TEXT,                                                              -- Username участника (опционально)
+    user_first_name TEXT,                                                            -- Имя участника
+    user_last_name TEXT,                                                             -- Фамилия участника (опционально)
+    submitted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),                                 -- Время отправки бюллетеня
+    CONSTRAINT unique_ballot_rank UNIQUE (poll_id, user_telegram_id, rank),
+    CONSTRAINT unique_ballot_option UNIQUE (poll_id, user_telegram_id, option_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ballots_poll_id ON voting.ballots(poll_id);
+
+COMMENT ON TABLE voting.ballots IS 'Бюллетени рейтинговых голосований (одна строка на ранжированный вариант)';
@@ -0,0 +1,3 @@
+-- Откат миграции 0006: сессии диалогов
+
+DROP TABLE IF EXISTS voting.dialog_sessions;
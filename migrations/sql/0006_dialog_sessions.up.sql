@@ -0,0 +1,15 @@
+-- Миграция 0006: хранение сессий диалогов в PostgreSQL
+-- Незавершенный мастер /createpoll и ранжирование переживают перезапуск бота.
+-- Сессия без активности удаляется после expires_at
+
+CREATE TABLE IF NOT EXISTS voting.dialog_sessions (
+    user_telegram_id BIGINT PRIMARY KEY,          -- Telegram ID пользователя
+    state TEXT NOT NULL,                          -- Состояние диалога
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,      -- Данные диалога (черновик голосования и т.д.)
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- Время последнего изменения
+    expires_at TIMESTAMPTZ NOT NULL               -- Время истечения сессии
+);
+
+CREATE INDEX IF NOT EXISTS idx_dialog_sessions_expires_at ON voting.dialog_sessions(expires_at);
+
+COMMENT ON TABLE voting.dialog_sessions IS 'Сессии диалогов пользователей с ботом (TTL по expires_at)';
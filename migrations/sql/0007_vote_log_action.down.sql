@@ -0,0 +1,3 @@
+-- Откат миграции 0007: итог нажатия в vote_log
+
+ALTER TABLE voting.vote_log DROP COLUMN IF EXISTS action;
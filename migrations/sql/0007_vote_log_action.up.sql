@@ -0,0 +1,11 @@
+-- Миграция 0007: итог нажатия в vote_log
+-- Повторное нажатие на текущий вариант отзывает голос, поэтому по одному option_id
+-- уже нельзя понять, что произошло. Записи, сделанные до миграции, остаются с NULL
+
+ALTER TABLE voting.vote_log ADD COLUMN IF NOT EXISTS action TEXT;
+
+ALTER TABLE voting.vote_log DROP CONSTRAINT IF EXISTS vote_log_action_check;
+ALTER TABLE voting.vote_log ADD CONSTRAINT vote_log_action_check
+    CHECK (action IN ('recorded', 'changed', 'withdrawn', 'selected', 'deselected', 'limit_reached', 'ballot'));
+
+COMMENT ON COLUMN voting.vote_log.action IS 'Итог нажатия: recorded, changed, withdrawn, selected, deselected, limit_reached, ballot';
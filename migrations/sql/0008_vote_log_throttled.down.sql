@@ -0,0 +1,10 @@
+-- Откат миграции 0008: отклоненные ограничением частоты нажатия в vote_log
+-- Записи throttled не проходят прежнюю проверку, поэтому удаляются
+
+DELETE FROM voting.vote_log WHERE action = 'throttled';
+
+ALTER TABLE voting.vote_log DROP CONSTRAINT IF EXISTS vote_log_action_check;
+ALTER TABLE voting.vote_log ADD CONSTRAINT vote_log_action_check
+    CHECK (action IN ('recorded', 'changed', 'withdrawn', 'selected', 'deselected', 'limit_reached', 'ballot'));
+
+COMMENT ON COLUMN voting.vote_log.action IS 'Итог нажатия: recorded, changed, withdrawn, selected, deselected, limit_reached, ballot';
@@ -0,0 +1,8 @@
+-- Миграция 0008: отклоненные ограничением частоты нажатия в vote_log
+-- Такие нажатия не меняют голос, но записываются, чтобы видеть попытки флуда
+
+ALTER TABLE voting.vote_log DROP CONSTRAINT IF EXISTS vote_log_action_check;
+ALTER TABLE voting.vote_log ADD CONSTRAINT vote_log_action_check
+    CHECK (action IN ('recorded', 'changed', 'withdrawn', 'selected', 'deselected', 'limit_reached', 'ballot', 'throttled'));
+
+COMMENT ON COLUMN voting.vote_log.action IS 'Итог нажатия: recorded, changed, withdrawn, selected, deselected, limit_reached, ballot, throttled';
@@ -0,0 +1,3 @@
+-- Откат миграции 0009: заблокированные пользователи
+
+DROP TABLE IF EXISTS voting.banned_users;
@@ -0,0 +1,10 @@
+-- Миграция 0009: пользователи, заблокированные администраторами бота
+-- Заблокированный пользователь не может создавать голосования и голосовать
+
+CREATE TABLE IF NOT EXISTS voting.banned_users (
+    user_telegram_id BIGINT PRIMARY KEY,           -- Telegram ID заблокированного пользователя
+    banned_by BIGINT NOT NULL,                     -- Telegram ID администратора
+    banned_at TIMESTAMPTZ NOT NULL DEFAULT NOW()   -- Время блокировки
+);
+
+COMMENT ON TABLE voting.banned_users IS 'Пользователи, заблокированные администраторами (/admin ban)';